@@ -0,0 +1,292 @@
+package httperr
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Challenge is an authentication challenge as described in RFC 7235. It is
+// rendered in the WWW-Authenticate header of a 401 response or the
+// Proxy-Authenticate header of a 407 response.
+type Challenge struct {
+	Scheme string            // the authentication scheme, e.g. "Basic" or "Bearer"
+	Params map[string]string // the auth-params of the challenge, e.g. "realm"
+}
+
+// Basic returns a Challenge for the Basic authentication scheme (RFC 7617).
+func Basic(realm string) Challenge {
+	return Challenge{
+		Scheme: "Basic",
+		Params: map[string]string{"realm": realm},
+	}
+}
+
+// Bearer returns a Challenge for the Bearer authentication scheme (RFC 6750).
+// Parameters that are empty are omitted from the challenge. errorCode should
+// be one of "invalid_request", "invalid_token" or "insufficient_scope".
+func Bearer(realm, errorCode, errorDescription, scope string) Challenge {
+	params := map[string]string{}
+	for k, v := range map[string]string{
+		"realm":             realm,
+		"error":             errorCode,
+		"error_description": errorDescription,
+		"scope":             scope,
+	} {
+		if v != "" {
+			params[k] = v
+		}
+	}
+	return Challenge{Scheme: "Bearer", Params: params}
+}
+
+// Realm returns the realm of the challenge, if specified.
+func (c Challenge) Realm() string {
+	return c.Params["realm"]
+}
+
+// String returns the challenge in the form used by the WWW-Authenticate header.
+// The realm parameter, if present, is always first.
+func (c Challenge) String() string {
+	keys := make([]string, 0, len(c.Params))
+	for k := range c.Params {
+		if k != "realm" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if _, ok := c.Params["realm"]; ok {
+		keys = append([]string{"realm"}, keys...)
+	}
+
+	params := make([]string, 0, len(keys))
+	for _, k := range keys {
+		params = append(params, k+"="+quoteString(c.Params[k]))
+	}
+	if len(params) == 0 {
+		return c.Scheme
+	}
+	return c.Scheme + " " + strings.Join(params, ", ")
+}
+
+func quoteString(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
+
+// ChallengeError is an Unauthorized or ProxyAuthRequired error that carries
+// one or more authentication challenges. When written to a client, the
+// challenges are rendered in the WWW-Authenticate (401) or
+// Proxy-Authenticate (407) header.
+type ChallengeError struct {
+	Value
+	Challenges []Challenge
+}
+
+// NewUnauthorized returns a new http.StatusUnauthorized error wrapping err
+// that presents the specified challenges to the client.
+func NewUnauthorized(err error, challenges ...Challenge) error {
+	return ChallengeError{
+		Value:      Value{StatusCode: http.StatusUnauthorized, Err: err},
+		Challenges: challenges,
+	}
+}
+
+// NewProxyAuthRequired returns a new http.StatusProxyAuthRequired error wrapping
+// err that presents the specified challenges to the client.
+func NewProxyAuthRequired(err error, challenges ...Challenge) error {
+	return ChallengeError{
+		Value:      Value{StatusCode: http.StatusProxyAuthRequired, Err: err},
+		Challenges: challenges,
+	}
+}
+
+// challengeHeader returns the name of the header that carries challenges
+// for statusCode.
+func challengeHeader(statusCode int) string {
+	if statusCode == http.StatusProxyAuthRequired {
+		return "Proxy-Authenticate"
+	}
+	return "WWW-Authenticate"
+}
+
+// WriteError writes an error response to w including the authentication challenges.
+func (e ChallengeError) WriteError(w http.ResponseWriter, r *http.Request) {
+	statusCode, _ := e.StatusCodeAndText()
+	headerName := challengeHeader(statusCode)
+	w.Header().Del(headerName)
+	for _, c := range e.Challenges {
+		w.Header().Add(headerName, c.String())
+	}
+	e.Value.WriteError(w, r)
+}
+
+var _ error = ChallengeError{}
+var _ Writer = ChallengeError{}
+
+// ParseChallenges parses the value of a WWW-Authenticate or Proxy-Authenticate
+// header into a list of challenges. Auth schemes that use the token68 form,
+// such as "Negotiate abc123==", have the token stored in the "token68" param.
+func ParseChallenges(header string) ([]Challenge, error) {
+	p := challengeParser{s: header}
+	var rv []Challenge
+	for {
+		p.skip(" \t,")
+		if p.done() {
+			return rv, nil
+		}
+
+		scheme := p.token()
+		if scheme == "" {
+			return nil, fmt.Errorf("invalid challenge %q: expected auth scheme at offset %d", header, p.pos)
+		}
+		c := Challenge{Scheme: scheme, Params: map[string]string{}}
+
+		for {
+			p.skip(" \t")
+			if p.done() {
+				break
+			}
+			if p.peek() == ',' {
+				// either the separator between params or between challenges. We
+				// only know which once we've seen what follows.
+				save := p.pos
+				p.skip(" \t,")
+				name := p.token()
+				p.skip(" \t")
+				if name == "" || p.done() || p.peek() != '=' {
+					p.pos = save
+					break
+				}
+				p.pos = save
+				p.skip(" \t,")
+			}
+
+			start := p.pos
+			name := p.token()
+
+			// token68 syntax: the token is followed by zero or more '=' and then
+			// the end of the challenge.
+			if name != "" && len(c.Params) == 0 {
+				end := p.pos
+				for end < len(p.s) && p.s[end] == '=' {
+					end++
+				}
+				rest := strings.TrimLeft(p.s[end:], " \t")
+				if rest == "" || rest[0] == ',' {
+					p.pos = end
+					c.Params["token68"] = p.s[start:end]
+					continue
+				}
+			}
+
+			p.skip(" \t")
+			if name == "" || p.done() || p.peek() != '=' {
+				return nil, fmt.Errorf("invalid challenge %q: expected auth-param at offset %d", header, start)
+			}
+			p.pos++ // '='
+			p.skip(" \t")
+
+			value, err := p.value()
+			if err != nil {
+				return nil, fmt.Errorf("invalid challenge %q: %v", header, err)
+			}
+			c.Params[strings.ToLower(name)] = value
+		}
+		rv = append(rv, c)
+	}
+}
+
+type challengeParser struct {
+	s   string
+	pos int
+}
+
+func (p *challengeParser) done() bool { return p.pos >= len(p.s) }
+func (p *challengeParser) peek() byte { return p.s[p.pos] }
+
+func (p *challengeParser) skip(chars string) {
+	for !p.done() && strings.IndexByte(chars, p.peek()) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *challengeParser) token() string {
+	start := p.pos
+	for !p.done() && isTokenChar(p.peek()) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *challengeParser) value() (string, error) {
+	if p.done() || p.peek() != '"' {
+		return p.token(), nil
+	}
+	p.pos++
+	var buf strings.Builder
+	for !p.done() {
+		ch := p.peek()
+		p.pos++
+		switch ch {
+		case '"':
+			return buf.String(), nil
+		case '\\':
+			if p.done() {
+				return "", fmt.Errorf("unterminated quoted-string")
+			}
+			buf.WriteByte(p.peek())
+			p.pos++
+		default:
+			buf.WriteByte(ch)
+		}
+	}
+	return "", fmt.Errorf("unterminated quoted-string")
+}
+
+// isTokenChar returns true if ch is a tchar as defined in RFC 7230 section 3.2.6,
+// or one of the additional characters permitted in token68.
+func isTokenChar(ch byte) bool {
+	switch {
+	case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~/", ch) >= 0
+}
+
+// AuthChallenges returns a ClientArg that turns 401 and 407 responses that
+// carry WWW-Authenticate or Proxy-Authenticate headers into a ChallengeError.
+// The Err of the ChallengeError is the error produced by the rest of the
+// chain of error handlers, for example a decoded JSON error, or else the
+// original Response.
+//
+// AuthChallenges must be given to Client before JSON. A JSON stage that
+// decodes the error does not call the stages after it, so an AuthChallenges
+// stage after it is never called, and the challenges are lost.
+func AuthChallenges() ClientArg {
+	return HandleErrors(func(next ErrorFunc) ErrorFunc {
+		return func(req *http.Request, resp *http.Response) error {
+			if resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusProxyAuthRequired {
+				return next(req, resp)
+			}
+
+			var challenges []Challenge
+			for _, header := range resp.Header[http.CanonicalHeaderKey(challengeHeader(resp.StatusCode))] {
+				c, err := ParseChallenges(header)
+				if err != nil {
+					continue
+				}
+				challenges = append(challenges, c...)
+			}
+			if len(challenges) == 0 {
+				return next(req, resp)
+			}
+			return ChallengeError{
+				Value:      Value{StatusCode: resp.StatusCode, Err: next(req, resp)},
+				Challenges: challenges,
+			}
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package httperr
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChallengeString(t *testing.T) {
+	assert.Equal(t, `Basic realm="example"`, Basic("example").String())
+	assert.Equal(t,
+		`Bearer realm="example", error="invalid_token", error_description="The access token \"foo\" expired"`,
+		Bearer("example", "invalid_token", `The access token "foo" expired`, "").String())
+	assert.Equal(t, `Bearer scope="read write"`, Bearer("", "", "", "read write").String())
+}
+
+func TestChallengeErrorWriteError(t *testing.T) {
+	h := HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+		return NewUnauthorized(errors.New("token expired"),
+			Bearer("example", "invalid_token", "", ""),
+			Basic("example"))
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, 401, w.Code)
+	assert.Equal(t, []string{
+		`Bearer realm="example", error="invalid_token"`,
+		`Basic realm="example"`,
+	}, w.Header()["Www-Authenticate"])
+	assert.Equal(t, "Unauthorized\n", string(w.Body.Bytes()))
+
+	h = HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+		return NewProxyAuthRequired(nil, Basic("proxy"))
+	})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, 407, w.Code)
+	assert.Equal(t, `Basic realm="proxy"`, w.Header().Get("Proxy-Authenticate"))
+}
+
+func TestParseChallenges(t *testing.T) {
+	testCases := []struct {
+		Header     string
+		Challenges []Challenge
+		Err        string
+	}{
+		{
+			Header:     `Basic realm="example"`,
+			Challenges: []Challenge{Basic("example")},
+		},
+		{
+			Header: `Bearer realm="example", error="invalid_token", error_description="say \"hi\"", Basic realm=simple`,
+			Challenges: []Challenge{
+				Bearer("example", "invalid_token", `say "hi"`, ""),
+				Basic("simple"),
+			},
+		},
+		{
+			Header: `Negotiate abc123==, Bearer`,
+			Challenges: []Challenge{
+				{Scheme: "Negotiate", Params: map[string]string{"token68": "abc123=="}},
+				{Scheme: "Bearer", Params: map[string]string{}},
+			},
+		},
+		{
+			Header: `Basic realm="unterminated`,
+			Err:    `invalid challenge "Basic realm=\"unterminated": unterminated quoted-string`,
+		},
+		{
+			Header: `Basic realm="x" bogus`,
+			Err:    `invalid challenge "Basic realm=\"x\" bogus": expected auth-param at offset 16`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Header, func(t *testing.T) {
+			challenges, err := ParseChallenges(testCase.Header)
+			if testCase.Err != "" {
+				assert.EqualError(t, err, testCase.Err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.Challenges, challenges)
+		})
+	}
+}
+
+func TestClientAuthChallenges(t *testing.T) {
+	transport := Transport{
+		Next: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			resp := http.Response{}
+			resp.StatusCode = 401
+			resp.Header = http.Header{}
+			resp.Header.Add("WWW-Authenticate", `Bearer realm="example", error="invalid_token"`)
+			resp.Body = ioutil.NopCloser(strings.NewReader(`token expired`))
+			return &resp, nil
+		}),
+	}
+	AuthChallenges()(&transport)
+
+	client := http.Client{Transport: transport}
+	_, err := client.Get("/foo")
+
+	var challengeErr ChallengeError
+	if !assert.True(t, errors.As(err, &challengeErr)) {
+		return
+	}
+	assert.Equal(t, []Challenge{Bearer("example", "invalid_token", "", "")}, challengeErr.Challenges)
+	assert.Equal(t, 401, challengeErr.StatusCode)
+
+	var respErr Response
+	assert.True(t, errors.As(err, &respErr))
+	body, _ := ioutil.ReadAll(respErr.Body)
+	assert.Equal(t, "token expired", string(body))
+}
@@ -0,0 +1,250 @@
+package httperr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Definition describes an error that an API can return.
+type Definition struct {
+	Code        string `json:"code" yaml:"code"`                                     // the application error code, e.g. "user_not_found"
+	NumericCode int    `json:"numeric_code,omitempty" yaml:"numeric_code,omitempty"` // the numeric form of the code (optional)
+	StatusCode  int    `json:"status" yaml:"status"`                                 // the HTTP status code
+	Title       string `json:"title,omitempty" yaml:"title,omitempty"`               // a short, human-readable summary, sent to clients
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`   // a longer explanation for the documentation
+	DocURL      string `json:"doc_url,omitempty" yaml:"doc_url,omitempty"`           // a link to the documentation of the error
+	Public      bool   `json:"public,omitempty" yaml:"public,omitempty"`             // true if the text of the underlying error is shown to clients
+
+	// Message is a template for the text of the error, with parameters in
+	// braces, e.g. "no such user: {name}". Each parameter must be described
+	// in Params. Message and Params are used by httperr-gen to generate
+	// constructor functions.
+	Message string  `json:"message,omitempty" yaml:"message,omitempty"`
+	Params  []Param `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+// Param describes a parameter of a Definition's Message.
+type Param struct {
+	Name string `json:"name" yaml:"name"` // the name of the parameter, as it appears in Message
+	Type string `json:"type" yaml:"type"` // the Go type of the parameter, e.g. "string"
+}
+
+// Catalog is a collection of error definitions, typically loaded from a
+// YAML or JSON file such as:
+//
+//	errors:
+//	  - code: user_not_found
+//	    numeric_code: 1001
+//	    status: 404
+//	    title: User not found
+//	    doc_url: https://example.com/docs/errors#user_not_found
+//
+// A Catalog is also an http.Handler that serves the definitions as a JSON
+// document, so that clients can discover every error an API can return.
+type Catalog struct {
+	Errors []Definition `json:"errors" yaml:"errors"`
+
+	byCode map[string]int
+}
+
+// NewCatalog returns a catalog containing defs, or an error if the
+// definitions are not valid.
+func NewCatalog(defs ...Definition) (*Catalog, error) {
+	c := &Catalog{Errors: defs}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ParseCatalog parses a catalog from data. The format is either "json" or "yaml".
+func ParseCatalog(data []byte, format string) (*Catalog, error) {
+	c := &Catalog{}
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(c); err != nil {
+			return nil, fmt.Errorf("cannot parse catalog: %v", err)
+		}
+	case "yaml", "yml":
+		if err := yaml.UnmarshalStrict(data, c); err != nil {
+			return nil, fmt.Errorf("cannot parse catalog: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("cannot parse catalog: unknown format %q", format)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadCatalog reads and parses the named catalog file from fsys, which may
+// be an embed.FS. The format of the file is determined from its extension.
+func LoadCatalog(fsys fs.FS, name string) (*Catalog, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseCatalog(data, strings.TrimPrefix(path.Ext(name), "."))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return c, nil
+}
+
+// LoadCatalogFile reads and parses the catalog file at filename.
+func LoadCatalogFile(filename string) (*Catalog, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseCatalog(data, strings.TrimPrefix(path.Ext(filename), "."))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return c, nil
+}
+
+// Validate checks that every definition has a unique code and numeric code
+// and a valid error status code.
+func (c *Catalog) Validate() error {
+	byCode := map[string]int{}
+	byNumber := map[int]string{}
+	for i, def := range c.Errors {
+		if def.Code == "" {
+			return fmt.Errorf("error %d: code is required", i)
+		}
+		if _, exists := byCode[def.Code]; exists {
+			return fmt.Errorf("error %q: duplicate code", def.Code)
+		}
+		byCode[def.Code] = i
+
+		if def.NumericCode != 0 {
+			if other, exists := byNumber[def.NumericCode]; exists {
+				return fmt.Errorf("error %q: numeric code %d is already used by %q", def.Code, def.NumericCode, other)
+			}
+			byNumber[def.NumericCode] = def.Code
+		}
+
+		if def.StatusCode < 400 || def.StatusCode > 599 {
+			return fmt.Errorf("error %q: invalid status %d", def.Code, def.StatusCode)
+		}
+
+		if _, _, err := def.MessageFormat(); err != nil {
+			return fmt.Errorf("error %q: %v", def.Code, err)
+		}
+	}
+	c.byCode = byCode
+	return nil
+}
+
+// Lookup returns the definition with the specified code.
+func (c *Catalog) Lookup(code string) (Definition, bool) {
+	if c.byCode == nil {
+		// the catalog was not constructed by NewCatalog or ParseCatalog
+		for _, def := range c.Errors {
+			if def.Code == code {
+				return def, true
+			}
+		}
+		return Definition{}, false
+	}
+	i, ok := c.byCode[code]
+	if !ok {
+		return Definition{}, false
+	}
+	return c.Errors[i], true
+}
+
+// New returns a new http error for the definition with the specified code,
+// wrapping err. If the code is not defined, an InternalServerError is returned.
+func (c *Catalog) New(code string, err error) error {
+	def, ok := c.Lookup(code)
+	if !ok {
+		if err == nil {
+			err = fmt.Errorf("undefined error code %q", code)
+		} else {
+			err = fmt.Errorf("undefined error code %q: %w", code, err)
+		}
+		return Value{StatusCode: http.StatusInternalServerError, Err: err}
+	}
+	return def.New(err)
+}
+
+// New returns a new http error for the definition wrapping err.
+func (def Definition) New(err error) error {
+	return Value{
+		StatusCode:  def.StatusCode,
+		Title:       def.Title,
+		Public:      def.Public,
+		Err:         err,
+		Code:        def.Code,
+		NumericCode: def.NumericCode,
+		Type:        def.DocURL,
+	}
+}
+
+// MessageFormat returns Message as a fmt format string, along with the
+// params in the order that they are referenced. Each parameter is formatted
+// with %v. For example, "no such user: {name}" becomes "no such user: %v".
+func (def Definition) MessageFormat() (string, []Param, error) {
+	paramsByName := map[string]Param{}
+	for _, param := range def.Params {
+		if param.Name == "" || param.Type == "" {
+			return "", nil, fmt.Errorf("params must have a name and a type")
+		}
+		paramsByName[param.Name] = param
+	}
+
+	var format strings.Builder
+	var params []Param
+	msg := def.Message
+	for {
+		i := strings.IndexAny(msg, "{%")
+		if i < 0 {
+			format.WriteString(msg)
+			break
+		}
+		format.WriteString(msg[:i])
+		if msg[i] == '%' {
+			format.WriteString("%%")
+			msg = msg[i+1:]
+			continue
+		}
+
+		end := strings.IndexByte(msg[i:], '}')
+		if end < 0 {
+			return "", nil, fmt.Errorf("message: unterminated parameter")
+		}
+		name := msg[i+1 : i+end]
+		param, ok := paramsByName[name]
+		if !ok {
+			return "", nil, fmt.Errorf("message: undefined parameter %q", name)
+		}
+		format.WriteString("%v")
+		params = append(params, param)
+		msg = msg[i+end+1:]
+	}
+	return format.String(), params, nil
+}
+
+// ServeHTTP serves the catalog as a JSON document.
+func (c *Catalog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		Write(w, r, MethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
@@ -0,0 +1,134 @@
+package httperr
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	catalog, err := LoadCatalog(os.DirFS("testdata"), "catalog.yaml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	def, ok := catalog.Lookup("user_not_found")
+	assert.True(t, ok)
+	assert.Equal(t, Definition{
+		Code:        "user_not_found",
+		NumericCode: 1001,
+		StatusCode:  404,
+		Title:       "User not found",
+		Description: "The requested user does not exist.",
+		DocURL:      "https://example.com/docs/errors#user_not_found",
+		Public:      true,
+	}, def)
+
+	_, ok = catalog.Lookup("nope")
+	assert.False(t, ok)
+
+	fsys := fstest.MapFS{
+		"errors.json": {Data: []byte(`{"errors": [{"code": "a", "status": 400}]}`)},
+		"dup.json":    {Data: []byte(`{"errors": [{"code": "a", "status": 400}, {"code": "a", "status": 404}]}`)},
+		"num.yml":     {Data: []byte("errors:\n- {code: a, numeric_code: 1, status: 400}\n- {code: b, numeric_code: 1, status: 400}\n")},
+		"status.yaml": {Data: []byte("errors:\n- {code: a, status: 200}\n")},
+		"typo.yaml":   {Data: []byte("errors:\n- {code: a, stauts: 400}\n")},
+		"typo.json":   {Data: []byte(`{"errors": [{"code": "a", "status": 400, "tilte": "A"}]}`)},
+		"errors.txt":  {Data: []byte(``)},
+	}
+
+	catalog, err = LoadCatalog(fsys, "errors.json")
+	assert.NoError(t, err)
+	assert.Len(t, catalog.Errors, 1)
+
+	_, err = LoadCatalog(fsys, "dup.json")
+	assert.EqualError(t, err, `dup.json: error "a": duplicate code`)
+	_, err = LoadCatalog(fsys, "num.yml")
+	assert.EqualError(t, err, `num.yml: error "b": numeric code 1 is already used by "a"`)
+	_, err = LoadCatalog(fsys, "status.yaml")
+	assert.EqualError(t, err, `status.yaml: error "a": invalid status 200`)
+	_, err = LoadCatalog(fsys, "typo.yaml")
+	assert.Error(t, err)
+	_, err = LoadCatalog(fsys, "typo.json")
+	assert.EqualError(t, err, `typo.json: cannot parse catalog: json: unknown field "tilte"`)
+	_, err = LoadCatalog(fsys, "errors.txt")
+	assert.EqualError(t, err, `errors.txt: cannot parse catalog: unknown format "txt"`)
+}
+
+func TestCatalogNew(t *testing.T) {
+	catalog, err := LoadCatalogFile("testdata/catalog.yaml")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = catalog.New("user_not_found", errors.New("no such user: alice"))
+	assert.True(t, errors.Is(err, Code{Name: "user_not_found"}))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/problem+json")
+	Write(w, r, err)
+	assert.Equal(t, 404, w.Code)
+	assert.JSONEq(t, `{"type": "https://example.com/docs/errors#user_not_found",
+		"title": "User not found", "status": 404, "detail": "no such user: alice",
+		"code": "user_not_found", "numeric_code": 1001}`, string(w.Body.Bytes()))
+
+	// the text body shows the detail or the status text, not the title
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/", nil)
+	Write(w, r, err)
+	assert.Equal(t, "no such user: alice\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	Write(w, r, catalog.New("quota_exceeded", errors.New("too many frobs")))
+	assert.Equal(t, 429, w.Code)
+	assert.Equal(t, "Too Many Requests\n", w.Body.String())
+
+	err = catalog.New("no_such_code", errors.New("oops"))
+	statusCode, _ := StatusCodeAndText(err)
+	assert.Equal(t, 500, statusCode)
+	assert.EqualError(t, err, `500 Internal Server Error: undefined error code "no_such_code": oops`)
+}
+
+func TestCatalogServeHTTP(t *testing.T) {
+	catalog, err := NewCatalog(Definition{Code: "quota_exceeded", StatusCode: 429, Title: "Quota exceeded"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/errors", nil)
+	catalog.ServeHTTP(w, r)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"errors": [{"code": "quota_exceeded", "status": 429, "title": "Quota exceeded"}]}`,
+		string(w.Body.Bytes()))
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("POST", "/errors", nil)
+	catalog.ServeHTTP(w, r)
+	assert.Equal(t, 405, w.Code)
+}
+
+func TestDefinitionMessageFormat(t *testing.T) {
+	def := Definition{
+		Code:    "user_not_found",
+		Message: "no such user: {name} ({name}, 100%)",
+		Params:  []Param{{Name: "name", Type: "string"}},
+	}
+	format, params, err := def.MessageFormat()
+	assert.NoError(t, err)
+	assert.Equal(t, "no such user: %v (%v, 100%%)", format)
+	assert.Equal(t, []Param{{Name: "name", Type: "string"}, {Name: "name", Type: "string"}}, params)
+
+	def.Message = "no such user: {id}"
+	_, _, err = def.MessageFormat()
+	assert.EqualError(t, err, `message: undefined parameter "id"`)
+
+	_, err = NewCatalog(Definition{Code: "a", StatusCode: 400, Message: "{x"})
+	assert.EqualError(t, err, `error "a": message: unterminated parameter`)
+}
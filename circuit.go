@@ -0,0 +1,292 @@
+package httperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit of a CircuitBreaker.
+type CircuitState int
+
+// Circuit states
+const (
+	CircuitClosed   CircuitState = iota // requests are allowed
+	CircuitOpen                         // requests fail without being sent
+	CircuitHalfOpen                     // a single request is allowed, to probe whether the dependency has recovered
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// ErrCircuitOpen is the underlying error of a CircuitOpenError, so that
+// errors.Is(err, httperr.ErrCircuitOpen) is true for requests that were
+// refused by a CircuitBreaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is returned by a Transport with a CircuitBreaker when a
+// request is refused because its circuit is open. It is a
+// http.StatusServiceUnavailable error.
+type CircuitOpenError struct {
+	Value
+	Key   string    // the key of the circuit
+	Until time.Time // when the circuit becomes half-open
+
+	now func() time.Time // the clock of the CircuitBreaker, if not time.Now
+}
+
+// RetryAfter returns how long until the circuit becomes half-open.
+func (e CircuitOpenError) RetryAfter() time.Duration {
+	now := time.Now
+	if e.now != nil {
+		now = e.now
+	}
+	if d := e.Until.Sub(now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// CircuitBreaker stops a client from sending requests to a dependency that
+// is failing. Each key, by default each host, has its own circuit. A
+// circuit opens after Threshold consecutive failures, and while it is open
+// requests fail immediately with a CircuitOpenError. After Cooldown the
+// circuit becomes half-open, and the next request is sent as a probe: if it
+// succeeds the circuit closes, and if it fails the circuit opens again.
+//
+// Use it with the Breaker ClientArg:
+//
+//	breaker := &httperr.CircuitBreaker{
+//	    OnStateChange: func(key string, from, to httperr.CircuitState) {
+//	        log.Printf("circuit %s: %s -> %s", key, from, to)
+//	    },
+//	}
+//	client := httperr.Client(http.DefaultClient, httperr.Breaker(breaker))
+//
+// A CircuitBreaker must not be copied after first use.
+type CircuitBreaker struct {
+	// Threshold is the number of consecutive failures that opens a circuit.
+	// If zero, 5 is used.
+	Threshold int
+
+	// Cooldown is how long a circuit stays open before becoming half-open.
+	// If zero, 30 seconds is used.
+	Cooldown time.Duration
+
+	// Key returns the key of the circuit for r. If nil, the host of the URL
+	// of the request is used.
+	Key func(r *http.Request) string
+
+	// IsFailure returns true if the result of a request counts as a failure.
+	// If nil, transport errors and responses with status codes >= 500 are
+	// failures. Requests canceled by the caller are neither failures nor
+	// successes, and IsFailure is not called for them.
+	IsFailure func(resp *http.Response, err error) bool
+
+	// OnStateChange, if not nil, is called when a circuit changes state. It
+	// is called with a lock held, so it must not use the CircuitBreaker.
+	OnStateChange func(key string, from, to CircuitState)
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+	now      func() time.Time // if nil, time.Now is used
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int       // consecutive failures while closed
+	until    time.Time // when an open circuit becomes half-open
+	probing  bool      // a half-open circuit has sent its probe
+}
+
+// State returns the state of the circuit for key.
+func (cb *CircuitBreaker) State(key string) CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	c := cb.circuits[key]
+	if c == nil {
+		return CircuitClosed
+	}
+	if c.state == CircuitOpen && !cb.timeNow().Before(c.until) {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+func (cb *CircuitBreaker) timeNow() time.Time {
+	if cb.now != nil {
+		return cb.now()
+	}
+	return time.Now()
+}
+
+func (cb *CircuitBreaker) threshold() int {
+	if cb.Threshold > 0 {
+		return cb.Threshold
+	}
+	return 5
+}
+
+func (cb *CircuitBreaker) cooldown() time.Duration {
+	if cb.Cooldown > 0 {
+		return cb.Cooldown
+	}
+	return 30 * time.Second
+}
+
+func (cb *CircuitBreaker) key(r *http.Request) string {
+	if cb.Key != nil {
+		return cb.Key(r)
+	}
+	return r.URL.Host
+}
+
+func (cb *CircuitBreaker) isFailure(resp *http.Response, err error) bool {
+	if cb.IsFailure != nil {
+		return cb.IsFailure(resp, err)
+	}
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500
+}
+
+// setState changes the state of c. The caller must hold cb.mu.
+func (cb *CircuitBreaker) setState(key string, c *circuit, state CircuitState) {
+	if c.state == state {
+		return
+	}
+	from := c.state
+	c.state = state
+	if cb.OnStateChange != nil {
+		cb.OnStateChange(key, from, state)
+	}
+}
+
+// allow returns nil if a request for key may be sent, or a
+// CircuitOpenError if not.
+func (cb *CircuitBreaker) allow(key string) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.circuits == nil {
+		cb.circuits = map[string]*circuit{}
+	}
+	c := cb.circuits[key]
+	if c == nil {
+		c = &circuit{}
+		cb.circuits[key] = c
+	}
+
+	if c.state == CircuitOpen && !cb.timeNow().Before(c.until) {
+		cb.setState(key, c, CircuitHalfOpen)
+		c.probing = false
+	}
+
+	switch c.state {
+	case CircuitOpen:
+	case CircuitHalfOpen:
+		if !c.probing {
+			c.probing = true
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	return CircuitOpenError{
+		Value: Value{
+			StatusCode: http.StatusServiceUnavailable,
+			Err:        fmt.Errorf("%s: %w", key, ErrCircuitOpen),
+		},
+		Key:   key,
+		Until: c.until,
+		now:   cb.now,
+	}
+}
+
+// record updates the circuit for key with the result of a request.
+func (cb *CircuitBreaker) record(key string, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c := cb.circuits[key]
+	switch {
+	case c.state == CircuitOpen:
+		// the request was sent before the circuit opened
+	case !failed:
+		c.failures = 0
+		c.probing = false
+		cb.setState(key, c, CircuitClosed)
+	case c.state == CircuitHalfOpen:
+		c.probing = false
+		c.until = cb.timeNow().Add(cb.cooldown())
+		cb.setState(key, c, CircuitOpen)
+	case c.state == CircuitClosed:
+		c.failures++
+		if c.failures >= cb.threshold() {
+			c.failures = 0
+			c.until = cb.timeNow().Add(cb.cooldown())
+			cb.setState(key, c, CircuitOpen)
+		}
+	}
+}
+
+// release frees the probe of the circuit for key, if it is half-open, without
+// recording a result. It is used when the caller cancels a request, which
+// says nothing about the health of the dependency.
+func (cb *CircuitBreaker) release(key string) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if c := cb.circuits[key]; c.state == CircuitHalfOpen {
+		c.probing = false
+	}
+}
+
+// Breaker returns a ClientArg that protects the requests made by the client
+// with cb. Requests refused by cb fail with a CircuitOpenError without being
+// passed to the Next transport.
+func Breaker(cb *CircuitBreaker) ClientArg {
+	return func(xport *Transport) {
+		xport.Next = breakerTransport{next: xport.Next, breaker: cb}
+	}
+}
+
+type breakerTransport struct {
+	next    http.RoundTripper
+	breaker *CircuitBreaker
+}
+
+func (t breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := t.breaker.key(req)
+	if err := t.breaker.allow(key); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil && (errors.Is(err, context.Canceled) || errors.Is(req.Context().Err(), context.Canceled)) {
+		t.breaker.release(key)
+		return resp, err
+	}
+	t.breaker.record(key, t.breaker.isFailure(resp, err))
+	return resp, err
+}
@@ -0,0 +1,173 @@
+package httperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testClock is a clock for tests that only moves when it is advanced.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestCircuitBreaker(t *testing.T) {
+	var transitions []string
+	clock := &testClock{now: time.Date(2020, 2, 18, 12, 0, 0, 0, time.UTC)}
+	breaker := &CircuitBreaker{
+		Threshold: 2,
+		Cooldown:  20 * time.Second,
+		OnStateChange: func(key string, from, to CircuitState) {
+			transitions = append(transitions, fmt.Sprintf("%s: %s -> %s", key, from, to))
+		},
+		now: clock.Now,
+	}
+
+	statusCode := http.StatusBadGateway
+	calls := 0
+	client := Client(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if req.URL.Host == "timeout.example.com" {
+			return nil, context.DeadlineExceeded
+		}
+		return &http.Response{StatusCode: statusCode, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})}, Breaker(breaker))
+
+	get := func(url string) error {
+		resp, err := client.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// 4xx errors are not failures
+	statusCode = http.StatusNotFound
+	for i := 0; i < 3; i++ {
+		assert.Error(t, get("http://a.example.com/"))
+	}
+	assert.Equal(t, CircuitClosed, breaker.State("a.example.com"))
+
+	statusCode = http.StatusBadGateway
+	assert.Error(t, get("http://a.example.com/"))
+	assert.Equal(t, CircuitClosed, breaker.State("a.example.com"))
+	assert.Error(t, get("http://a.example.com/"))
+	assert.Equal(t, CircuitOpen, breaker.State("a.example.com"))
+
+	// while open, requests are refused without calling Next
+	calls = 0
+	err := get("http://a.example.com/")
+	assert.Equal(t, 0, calls)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	var openErr CircuitOpenError
+	if assert.True(t, errors.As(err, &openErr)) {
+		assert.Equal(t, "a.example.com", openErr.Key)
+		assert.Equal(t, clock.now.Add(20*time.Second), openErr.Until)
+		assert.Equal(t, "503 Service Unavailable: a.example.com: circuit breaker is open", openErr.Error())
+	}
+	statusCode, _ = StatusCodeAndText(err)
+	assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+	clock.Advance(5 * time.Second)
+	delay, ok := RetryAfter(err)
+	assert.True(t, ok)
+	assert.Equal(t, 15*time.Second, delay)
+
+	// other hosts are unaffected
+	statusCode = http.StatusOK
+	assert.NoError(t, get("http://b.example.com/"))
+
+	// after the cooldown, a failed probe opens the circuit again
+	clock.Advance(15 * time.Second)
+	assert.Equal(t, CircuitHalfOpen, breaker.State("a.example.com"))
+	statusCode = http.StatusServiceUnavailable
+	assert.Error(t, get("http://a.example.com/"))
+	assert.Equal(t, CircuitOpen, breaker.State("a.example.com"))
+
+	// and a successful probe closes it
+	clock.Advance(20 * time.Second)
+	statusCode = http.StatusOK
+	assert.NoError(t, get("http://a.example.com/"))
+	assert.Equal(t, CircuitClosed, breaker.State("a.example.com"))
+
+	// timeouts are failures
+	for i := 0; i < 2; i++ {
+		assert.Error(t, get("http://timeout.example.com/"))
+	}
+	assert.Equal(t, CircuitOpen, breaker.State("timeout.example.com"))
+
+	assert.Equal(t, []string{
+		"a.example.com: closed -> open",
+		"a.example.com: open -> half-open",
+		"a.example.com: half-open -> open",
+		"a.example.com: open -> half-open",
+		"a.example.com: half-open -> closed",
+		"timeout.example.com: closed -> open",
+	}, transitions)
+}
+
+func TestCircuitBreakerHalfOpenProbe(t *testing.T) {
+	clock := &testClock{now: time.Date(2020, 2, 18, 12, 0, 0, 0, time.UTC)}
+	breaker := &CircuitBreaker{
+		Threshold: 1,
+		Cooldown:  time.Second,
+		Key:       func(*http.Request) string { return "api" },
+		now:       clock.Now,
+	}
+	assert.NoError(t, breaker.allow("api"))
+	breaker.record("api", true)
+	clock.Advance(time.Second)
+
+	// only one probe is allowed while half-open
+	assert.NoError(t, breaker.allow("api"))
+	assert.True(t, errors.Is(breaker.allow("api"), ErrCircuitOpen))
+	breaker.record("api", false)
+	assert.NoError(t, breaker.allow("api"))
+}
+
+func TestCircuitBreakerCanceled(t *testing.T) {
+	clock := &testClock{now: time.Date(2020, 2, 18, 12, 0, 0, 0, time.UTC)}
+	breaker := &CircuitBreaker{Threshold: 2, Cooldown: 20 * time.Second, now: clock.Now}
+	statusCode := http.StatusBadGateway
+	client := Client(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		return &http.Response{StatusCode: statusCode, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})}, Breaker(breaker))
+
+	get := func(ctx context.Context) error {
+		req, _ := http.NewRequestWithContext(ctx, "GET", "http://a.example.com/", nil)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// a cancellation does not reset the count of consecutive failures
+	assert.Error(t, get(context.Background()))
+	assert.True(t, errors.Is(get(canceled), context.Canceled))
+	assert.Error(t, get(context.Background()))
+	assert.Equal(t, CircuitOpen, breaker.State("a.example.com"))
+
+	// a canceled probe leaves the circuit half-open, and frees the probe
+	clock.Advance(20 * time.Second)
+	assert.True(t, errors.Is(get(canceled), context.Canceled))
+	assert.Equal(t, CircuitHalfOpen, breaker.State("a.example.com"))
+	statusCode = http.StatusOK
+	assert.NoError(t, get(context.Background()))
+	assert.Equal(t, CircuitClosed, breaker.State("a.example.com"))
+}
@@ -3,9 +3,12 @@ package httperr
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"reflect"
+	"sync"
 )
 
 // ClientArg is an argument to Client
@@ -35,10 +38,10 @@ var _ http.RoundTripper = Transport{}
 // Transport is an http.RoundTripper that intercepts responses where
 // the StatusCode >= 400 and returns a Response{}.
 //
-// If ErrorFactory is specified it should return an error that can be used
-// to unmarshal a JSON error response. This is useful when a web service
-// offers structured error information. If the error structure cannot be
-// unmarshalled, then a regular Response error is returned.
+// The error can be customized by a chain of error handlers, added by
+// ClientArgs such as JSON, RateLimits and HandleErrors. This is useful when
+// a web service offers structured error information. If the error structure
+// cannot be unmarshalled, then a regular Response error is returned.
 //
 //    type APIError struct {
 //      Code string `json:"code"`
@@ -49,15 +52,107 @@ var _ http.RoundTripper = Transport{}
 //       return fmt.Sprintf("%s (%d)", a.Message, a.Code)
 //    }
 //
-//    t := Transport{
-//        ErrorFactory: func() error {
-//            return &APIError{}
-//        },
-//    }
+//    client := httperr.Client(http.DefaultClient, httperr.RateLimits(), httperr.JSON(APIError{}))
 //
 type Transport struct {
 	Next    http.RoundTripper
 	OnError func(req *http.Request, resp *http.Response) error
+
+	// ErrorHandlers is the chain of stages that produce the error for a
+	// response with a status code >= 400. The first stage is called first,
+	// and OnError, if not nil, is the last. If the chain returns nil, the
+	// error is Response(*resp).
+	ErrorHandlers []ErrorMiddleware
+
+	// Tracer, if not nil, is notified of failed requests so that it can
+	// annotate the active trace span.
+	Tracer Tracer
+
+	// Observers are notified of the outcome of every request, in order.
+	Observers []Observer
+
+	// MaxErrorBodySize is the maximum number of bytes of the body of an
+	// error response that are read by the JSON and BufferErrorBodies
+	// ClientArgs. If zero, DefaultMaxErrorBodySize is used, and if
+	// negative, bodies are read without a limit.
+	MaxErrorBodySize int64
+}
+
+// Observer is notified of the outcome of requests made through a Transport,
+// for example to log them or record metrics. Observers cannot change the
+// outcome, and must not read or close the body of a response. Any of the
+// functions may be nil.
+type Observer struct {
+	// BeforeRequest is called before each request is sent.
+	BeforeRequest func(req *http.Request)
+
+	// AfterResponse is called when a response is received, whatever its
+	// status code.
+	AfterResponse func(req *http.Request, resp *http.Response)
+
+	// OnTransportError is called when no response is received.
+	OnTransportError func(req *http.Request, err error)
+
+	// OnStatusError is called when a response has a status code >= 400, with
+	// the error that the Transport returns for it.
+	OnStatusError func(req *http.Request, resp *http.Response, err error)
+}
+
+// Observe returns a ClientArg that adds o to the observers of the
+// Transport. It may be used several times to add several observers.
+func Observe(o Observer) ClientArg {
+	return func(xport *Transport) {
+		xport.Observers = append(xport.Observers, o)
+	}
+}
+
+// ErrorFunc returns the error for a response with a status code >= 400.
+type ErrorFunc func(req *http.Request, resp *http.Response) error
+
+// ErrorMiddleware is a stage of the chain of error handlers of a Transport.
+// It returns an ErrorFunc that may call next, the rest of the chain, in
+// order to:
+//
+//   - decode the response into an error, and return it without calling next
+//   - enrich the error returned by next, for example by wrapping it
+//   - replace the error returned by next
+//   - pass the response on, by returning next(req, resp)
+//
+// A stage must call next at most once. A stage that reads the body of the
+// response must replace it, so that the other stages, and the caller, can
+// read it too.
+type ErrorMiddleware func(next ErrorFunc) ErrorFunc
+
+// HandleErrors returns a ClientArg that adds stages to the end of the chain
+// of error handlers of the Transport. ClientArgs add their stages in the
+// order they are given to Client, so the first is called first. A stage
+// that decodes the error does not call the stages after it, so stages that
+// enrich the error, such as RateLimits, go before those that decode it,
+// such as JSON.
+func HandleErrors(stages ...ErrorMiddleware) ClientArg {
+	return func(xport *Transport) {
+		xport.ErrorHandlers = append(xport.ErrorHandlers, stages...)
+	}
+}
+
+// handleError returns the error for resp, from the chain of error handlers.
+func (t Transport) handleError(req *http.Request, resp *http.Response) error {
+	h := func(req *http.Request, resp *http.Response) error {
+		if t.OnError != nil {
+			if err := t.OnError(req, resp); err != nil {
+				return err
+			}
+		}
+		return Response(*resp)
+	}
+	for i := len(t.ErrorHandlers) - 1; i >= 0; i-- {
+		h = t.ErrorHandlers[i](h)
+	}
+
+	if err := h(req, resp); err != nil {
+		return err
+	}
+	return Response(*resp)
 }
 
 // RoundTrip implements http.RoundTripper.
@@ -67,25 +162,52 @@ func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 		next = http.DefaultTransport
 	}
 
+	for _, o := range t.Observers {
+		if o.BeforeRequest != nil {
+			o.BeforeRequest(req)
+		}
+	}
+
 	resp, err := next.RoundTrip(req)
 	if err != nil {
+		for _, o := range t.Observers {
+			if o.OnTransportError != nil {
+				o.OnTransportError(req, err)
+			}
+		}
+		if t.Tracer != nil {
+			t.Tracer.RecordError(req.Context(), newTraceEvent(err, 0, true))
+		}
 		return nil, err
 	}
+	for _, o := range t.Observers {
+		if o.AfterResponse != nil {
+			o.AfterResponse(req, resp)
+		}
+	}
 	if resp.StatusCode < 400 {
 		return resp, nil
 	}
 
-	if t.OnError != nil {
-		if err := t.OnError(req, resp); err != nil {
-			return nil, err
+	err = t.handleError(req, resp)
+
+	for _, o := range t.Observers {
+		if o.OnStatusError != nil {
+			o.OnStatusError(req, resp, err)
 		}
 	}
 
-	return nil, Response(*resp)
+	if t.Tracer != nil {
+		t.Tracer.RecordError(req.Context(), newTraceEvent(err, resp.StatusCode, true))
+	}
+	return nil, err
 }
 
-// JSON returns a ClientArg that specifies a function that
-// handles errors structured as a JSON object.
+// JSON returns a ClientArg that adds a stage to the chain of error
+// handlers that decodes error responses structured as a JSON object into
+// a new value of the same type as errStruct. Responses that cannot be
+// decoded, or whose bodies are larger than the MaxErrorBodySize of the
+// Transport, are passed on to the rest of the chain.
 func JSON(errStruct error) ClientArg {
 	typ := reflect.TypeOf(errStruct)
 	if typ.Kind() != reflect.Struct {
@@ -96,25 +218,179 @@ func JSON(errStruct error) ClientArg {
 	_ = e.(error) // panic if errStruct
 
 	return func(xport *Transport) {
-		xport.OnError = func(req *http.Request, resp *http.Response) error {
-			defer resp.Body.Close()
-			body, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				return err
-			}
-			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		HandleErrors(func(next ErrorFunc) ErrorFunc {
+			return func(req *http.Request, resp *http.Response) error {
+				buf := getErrorBodyBuffer()
+				defer putErrorBodyBuffer(buf)
+
+				complete, err := readErrorBody(resp, buf, xport.maxErrorBodySize())
+				if err != nil {
+					return err
+				}
+				if !complete {
+					// the body is too large to decode, so leave it for the
+					// rest of the chain, or the caller, to read
+					return next(req, resp)
+				}
+
+				jsonErrValue := reflect.New(typ)
 
-			jsonErrValue := reflect.New(typ)
+				unmarshalErr := json.Unmarshal(buf.Bytes(), jsonErrValue.Interface())
+				resp.Body = ioutil.NopCloser(bytes.NewReader(append([]byte(nil), buf.Bytes()...)))
+				if unmarshalErr == nil {
+					// jsonErrValue is a *Foo if errStruct == Foo{}
+					return jsonErrValue.Elem().Interface().(error)
+				}
 
-			unmarshalErr := json.Unmarshal(body, jsonErrValue.Interface())
-			if unmarshalErr == nil {
-				// jsonErrValue is a *Foo if errStruct == Foo{}
-				return jsonErrValue.Elem().Interface().(error)
+				// we failed to unmarshal the response body, so ignore the
+				// JSON error and proceed as if JSON() was not used.
+				return next(req, resp)
 			}
+		})(xport)
+	}
+}
 
-			// we failed to unmarshal the response body, so ignore the
-			// JSON error and proceed as if ErrorFactory was not provided.
-			return Response(*resp)
-		}
+// DefaultMaxErrorBodySize is the MaxErrorBodySize of a Transport that does
+// not specify one.
+const DefaultMaxErrorBodySize = 1 << 20
+
+// LimitErrorBodies returns a ClientArg that sets the MaxErrorBodySize of
+// the Transport to n. The limit applies to the JSON and BufferErrorBodies
+// ClientArgs whether they are given before or after it.
+func LimitErrorBodies(n int64) ClientArg {
+	return func(xport *Transport) {
+		xport.MaxErrorBodySize = n
+	}
+}
+
+// maxErrorBodySize returns the limit on the size of error bodies, or zero
+// if there is none.
+func (t *Transport) maxErrorBodySize() int64 {
+	switch {
+	case t.MaxErrorBodySize == 0:
+		return DefaultMaxErrorBodySize
+	case t.MaxErrorBodySize < 0:
+		return 0
+	}
+	return t.MaxErrorBodySize
+}
+
+// maxPooledBufferSize is the capacity above which buffers are not returned
+// to errorBodyPool, so that one large body does not pin its memory.
+const maxPooledBufferSize = 64 << 10
+
+var errorBodyPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getErrorBodyBuffer() *bytes.Buffer {
+	buf := errorBodyPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putErrorBodyBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBufferSize {
+		errorBodyPool.Put(buf)
+	}
+}
+
+// readErrorBody reads the body of resp into buf, up to limit bytes, or
+// without a limit if limit is zero. If the whole body fits, it closes the
+// body and returns true, and the caller must replace resp.Body if it wants
+// the body to be read again. Otherwise it replaces resp.Body with a body
+// that yields the bytes read followed by the remainder, and returns false,
+// as it does for a body that was truncated by BufferErrorBodies.
+func readErrorBody(resp *http.Response, buf *bytes.Buffer, limit int64) (bool, error) {
+	var err error
+	if limit <= 0 {
+		_, err = buf.ReadFrom(resp.Body)
+	} else {
+		_, err = buf.ReadFrom(io.LimitReader(resp.Body, limit+1))
+	}
+	if errors.Is(err, ErrBodyTruncated) {
+		// BufferErrorBodies kept only part of the body, which cannot be decoded
+		resp.Body = ioutil.NopCloser(io.MultiReader(
+			bytes.NewReader(append([]byte(nil), buf.Bytes()...)),
+			truncatedReader{}))
+		return false, nil
 	}
+	if limit <= 0 || err != nil {
+		resp.Body.Close()
+		return err == nil, err
+	}
+	if int64(buf.Len()) <= limit {
+		resp.Body.Close()
+		return true, nil
+	}
+
+	head := append([]byte(nil), buf.Bytes()...)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+	return false, nil
+}
+
+// ErrBodyTruncated is returned when reading the body of an error response
+// that BufferErrorBodies truncated, after the bytes that were kept.
+var ErrBodyTruncated = errors.New("httperr: error response body truncated")
+
+// BufferErrorBodies returns a ClientArg that reads the body of each error
+// response, up to the MaxErrorBodySize of the Transport, and closes it
+// before the error is handled. Error handlers, and callers that read the
+// Body of a Response error, see the bytes that were read. This frees
+// callers that only log errors from having to close their bodies, and
+// allows connections to be reused if the body fits.
+//
+// The body of a larger error response is truncated: reading it yields the
+// first MaxErrorBodySize bytes, and then ErrBodyTruncated instead of io.EOF.
+func BufferErrorBodies() ClientArg {
+	return func(xport *Transport) {
+		xport.Next = bufferingTransport{next: xport.Next, xport: xport}
+	}
+}
+
+type bufferingTransport struct {
+	next  http.RoundTripper
+	xport *Transport // the Transport whose MaxErrorBodySize applies
+}
+
+func (t bufferingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil || resp.StatusCode < 400 || resp.Body == nil {
+		return resp, err
+	}
+
+	limit := t.xport.maxErrorBodySize()
+	var body []byte
+	if limit > 0 {
+		body, err = ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	} else {
+		body, err = ioutil.ReadAll(resp.Body)
+	}
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && int64(len(body)) > limit {
+		resp.Body = ioutil.NopCloser(io.MultiReader(
+			bytes.NewReader(body[:limit]),
+			truncatedReader{}))
+		return resp, nil
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// truncatedReader is the end of a truncated body.
+type truncatedReader struct{}
+
+func (truncatedReader) Read([]byte) (int, error) {
+	return 0, ErrBodyTruncated
 }
@@ -1,12 +1,18 @@
 package httperr
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -26,6 +32,14 @@ func (te testError) Error() string {
 	return fmt.Sprintf("%s (%d)", te.Message, te.Code)
 }
 
+type otherError struct {
+	Message string `json:"message"`
+}
+
+func (oe otherError) Error() string {
+	return oe.Message
+}
+
 func TestClient(t *testing.T) {
 	transport := Transport{
 		Next: roundTripperFunc(func(*http.Request) (*http.Response, error) {
@@ -106,3 +120,321 @@ func TestClient(t *testing.T) {
 	})
 
 }
+
+// trackingBody is a response body that records whether it was closed
+type trackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestJSONBodyLimit(t *testing.T) {
+	var body *trackingBody
+	next := &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 400, Body: body}, nil
+	})}
+	client := Client(next, JSON(testError{}), LimitErrorBodies(16))
+
+	t.Run("small", func(t *testing.T) {
+		body = &trackingBody{Reader: strings.NewReader(`{"code": 1}`)}
+		_, err := client.Get("/foo")
+		assert.Equal(t, testError{Code: 1}, err.(*url.Error).Unwrap())
+		assert.True(t, body.closed)
+	})
+
+	t.Run("large", func(t *testing.T) {
+		large := `{"message": "` + strings.Repeat("x", 1000) + `", "code": 1}`
+		body = &trackingBody{Reader: strings.NewReader(large)}
+		_, err := client.Get("/foo")
+		httpErr := err.(*url.Error).Unwrap().(Response)
+		assert.Equal(t, 400, httpErr.StatusCode)
+		assert.False(t, body.closed)
+
+		// the whole body can still be read
+		buf, err := ioutil.ReadAll(httpErr.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, large, string(buf))
+		assert.NoError(t, httpErr.Body.Close())
+		assert.True(t, body.closed)
+	})
+
+	t.Run("endless", func(t *testing.T) {
+		body = &trackingBody{Reader: endlessReader{}}
+		_, err := client.Get("/foo")
+		httpErr := err.(*url.Error).Unwrap().(Response)
+		buf := make([]byte, 100)
+		n, err := io.ReadFull(httpErr.Body, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, strings.Repeat("{", n), string(buf))
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		client := Client(next, LimitErrorBodies(-1), JSON(testError{}))
+		body = &trackingBody{Reader: strings.NewReader(`{"message": "` + strings.Repeat("x", 1000) + `", "code": 1}`)}
+		_, err := client.Get("/foo")
+		assert.Equal(t, 1, err.(*url.Error).Unwrap().(testError).Code)
+		assert.True(t, body.closed)
+	})
+}
+
+func TestErrorBodyPool(t *testing.T) {
+	buf := getErrorBodyBuffer()
+	buf.Write(make([]byte, 2*maxPooledBufferSize))
+	putErrorBodyBuffer(buf)
+
+	// the large buffer is not reused
+	for i := 0; i < 10; i++ {
+		assert.True(t, getErrorBodyBuffer().Cap() <= maxPooledBufferSize)
+	}
+}
+
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = '{'
+	}
+	return len(p), nil
+}
+
+func TestBufferErrorBodies(t *testing.T) {
+	var connections int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, strings.Repeat("x", 10000))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := Client(server.Client(), BufferErrorBodies())
+	for i := 0; i < 5; i++ {
+		// the error is ignored without closing its body
+		_, err := client.Get(server.URL)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&connections))
+
+	// the body can still be read from the error
+	_, err := client.Get(server.URL)
+	httpErr := err.(*url.Error).Unwrap().(Response)
+	body, err := ioutil.ReadAll(httpErr.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, 10000, len(body))
+	assert.NoError(t, CloseBody(err))
+}
+
+func TestBufferErrorBodiesLimit(t *testing.T) {
+	body := &trackingBody{Reader: endlessReader{}}
+	client := Client(&http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 400, Body: body}, nil
+	})}, BufferErrorBodies(), LimitErrorBodies(4))
+
+	_, err := client.Get("/foo")
+	assert.True(t, body.closed)
+	buf, err := ioutil.ReadAll(err.(*url.Error).Unwrap().(Response).Body)
+	assert.Equal(t, "{{{{", string(buf))
+	assert.Equal(t, ErrBodyTruncated, err)
+
+	// a truncated body is not decoded
+	jsonClient := Client(&http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 400, Body: body}, nil
+	})}, BufferErrorBodies(), LimitErrorBodies(4), JSON(testError{}))
+	body = &trackingBody{Reader: strings.NewReader(`{"code": 1}`)}
+	_, err = jsonClient.Get("/foo")
+	buf, err = ioutil.ReadAll(err.(*url.Error).Unwrap().(Response).Body)
+	assert.Equal(t, `{"co`, string(buf))
+	assert.Equal(t, ErrBodyTruncated, err)
+
+	// a body of exactly the limit is not truncated
+	body = &trackingBody{Reader: strings.NewReader("{{{{")}
+	_, err = client.Get("/foo")
+	buf, err = ioutil.ReadAll(err.(*url.Error).Unwrap().(Response).Body)
+	assert.Equal(t, "{{{{", string(buf))
+	assert.NoError(t, err)
+}
+
+func TestCloseBody(t *testing.T) {
+	var body *trackingBody
+	client := Client(&http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 400, Body: body}, nil
+	})})
+
+	body = &trackingBody{Reader: strings.NewReader("")}
+	_, err := client.Get("/foo")
+	assert.False(t, body.closed)
+	assert.NoError(t, CloseBody(err))
+	assert.True(t, body.closed)
+
+	assert.NoError(t, CloseBody(nil))
+	assert.NoError(t, CloseBody(fmt.Errorf("oops")))
+	assert.NoError(t, Response{}.CloseBody())
+}
+
+func TestObservers(t *testing.T) {
+	var events []string
+	observer := func(name string) Observer {
+		return Observer{
+			BeforeRequest: func(req *http.Request) {
+				events = append(events, fmt.Sprintf("%s: before %s", name, req.URL.Path))
+			},
+			AfterResponse: func(req *http.Request, resp *http.Response) {
+				events = append(events, fmt.Sprintf("%s: response %d", name, resp.StatusCode))
+			},
+			OnTransportError: func(req *http.Request, err error) {
+				events = append(events, fmt.Sprintf("%s: transport error %v", name, err))
+			},
+			OnStatusError: func(req *http.Request, resp *http.Response, err error) {
+				events = append(events, fmt.Sprintf("%s: status error %v", name, err))
+			},
+		}
+	}
+
+	client := Client(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/ok":
+			return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+		case "/error":
+			return &http.Response{StatusCode: 400, Body: ioutil.NopCloser(strings.NewReader(`{"message": "bad", "code": 7}`))}, nil
+		}
+		return nil, fmt.Errorf("connection refused")
+	})}, Observe(observer("a")), JSON(testError{}), Observe(Observer{}), Observe(observer("b")))
+
+	resp, err := client.Get("/ok")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	_, err = client.Get("/error")
+	assert.Equal(t, testError{Message: "bad", Code: 7}, err.(*url.Error).Unwrap())
+	_, err = client.Get("/down")
+	assert.EqualError(t, err, `Get "/down": connection refused`)
+
+	assert.Equal(t, []string{
+		"a: before /ok",
+		"b: before /ok",
+		"a: response 200",
+		"b: response 200",
+		"a: before /error",
+		"b: before /error",
+		"a: response 400",
+		"b: response 400",
+		"a: status error bad (7)",
+		"b: status error bad (7)",
+		"a: before /down",
+		"b: before /down",
+		"a: transport error connection refused",
+		"b: transport error connection refused",
+	}, events)
+}
+
+func TestErrorHandlers(t *testing.T) {
+	newClient := func(args ...ClientArg) *http.Client {
+		return Client(&http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 429,
+				Header:     http.Header{"Retry-After": {"30"}},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"message": "slow down", "code": 3}`)),
+			}, nil
+		})}, args...)
+	}
+	get := func(client *http.Client) error {
+		_, err := client.Get("/foo")
+		return err.(*url.Error).Unwrap()
+	}
+
+	t.Run("order", func(t *testing.T) {
+		var calls []string
+		stage := func(name string) ErrorMiddleware {
+			return func(next ErrorFunc) ErrorFunc {
+				return func(req *http.Request, resp *http.Response) error {
+					calls = append(calls, name)
+					return next(req, resp)
+				}
+			}
+		}
+		client := newClient(HandleErrors(stage("a"), stage("b")), HandleErrors(stage("c")), func(xport *Transport) {
+			xport.OnError = func(req *http.Request, resp *http.Response) error {
+				calls = append(calls, "OnError")
+				return nil
+			}
+		})
+		err := get(client)
+		assert.Equal(t, []string{"a", "b", "c", "OnError"}, calls)
+		assert.Equal(t, 429, err.(Response).StatusCode)
+	})
+
+	t.Run("decode and enrich", func(t *testing.T) {
+		// RateLimits wraps the error decoded by the later JSON stage
+		err := get(newClient(RateLimits(), JSON(testError{})))
+		var retryErr RetryError
+		if assert.True(t, errors.As(err, &retryErr)) {
+			assert.Equal(t, 30*time.Second, retryErr.Delay)
+		}
+		var decoded testError
+		if assert.True(t, errors.As(err, &decoded)) {
+			assert.Equal(t, testError{Message: "slow down", Code: 3}, decoded)
+		}
+
+		// JSON decodes the error without calling the later RateLimits stage
+		err = get(newClient(JSON(testError{}), RateLimits()))
+		assert.Equal(t, testError{Message: "slow down", Code: 3}, err)
+	})
+
+	t.Run("first decoder wins", func(t *testing.T) {
+		err := get(newClient(JSON(otherError{}), JSON(testError{})))
+		assert.Equal(t, otherError{Message: "slow down"}, err)
+
+		// Problem cannot decode the numeric code, so it passes the response on
+		err = get(newClient(JSON(Problem{}), JSON(otherError{})))
+		assert.Equal(t, otherError{Message: "slow down"}, err)
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		errQuota := fmt.Errorf("quota exceeded")
+		client := newClient(HandleErrors(func(next ErrorFunc) ErrorFunc {
+			return func(req *http.Request, resp *http.Response) error {
+				err := next(req, resp)
+				if errors.As(err, &testError{}) {
+					return errQuota
+				}
+				return err
+			}
+		}), JSON(testError{}))
+		assert.Equal(t, errQuota, get(client))
+	})
+
+	t.Run("decoded body can be read", func(t *testing.T) {
+		var body string
+		client := newClient(HandleErrors(func(next ErrorFunc) ErrorFunc {
+			return func(req *http.Request, resp *http.Response) error {
+				err := next(req, resp)
+				buf, _ := ioutil.ReadAll(resp.Body)
+				body = string(buf)
+				return err
+			}
+		}), JSON(testError{}))
+		assert.Equal(t, testError{Message: "slow down", Code: 3}, get(client))
+		assert.Equal(t, `{"message": "slow down", "code": 3}`, body)
+	})
+
+	t.Run("body is preserved for later stages", func(t *testing.T) {
+		var body string
+		client := newClient(JSON(Problem{}), HandleErrors(func(next ErrorFunc) ErrorFunc {
+			return func(req *http.Request, resp *http.Response) error {
+				buf, _ := ioutil.ReadAll(resp.Body)
+				body = string(buf)
+				return nil
+			}
+		}))
+		err := get(client)
+		assert.Equal(t, 429, err.(Response).StatusCode)
+		assert.Equal(t, `{"message": "slow down", "code": 3}`, body)
+	})
+}
@@ -0,0 +1,319 @@
+// Command httperr-gen generates Go error values and documentation from an
+// httperr error catalog.
+//
+// Usage:
+//
+//	httperr-gen [-package name] [-o errors_gen.go] [-doc ERRORS.md] catalog.yaml
+//
+// For each definition in the catalog, httperr-gen generates a registered
+// httperr.Code that can be used with errors.Is, and a constructor function.
+// If the definition has a message, the constructor takes the message's
+// parameters as typed arguments, otherwise it takes the underlying error.
+// For example, the definition:
+//
+//	errors:
+//	  - code: user_not_found
+//	    status: 404
+//	    title: User not found
+//	    public: true
+//	    message: "no such user: {name}"
+//	    params:
+//	      - {name: name, type: string}
+//
+// produces:
+//
+//	var UserNotFound = httperr.RegisterCode("user_not_found", 0, 404)
+//
+//	func NewUserNotFound(name string) error
+//
+// It is typically invoked from a go:generate directive:
+//
+//	//go:generate httperr-gen -o errors_gen.go -doc ERRORS.md errors.yaml
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/crewjam/httperr"
+)
+
+func main() {
+	packageName := flag.String("package", os.Getenv("GOPACKAGE"), "the name of the generated package")
+	outputPath := flag.String("o", "", "the path of the generated Go file (default: stdout)")
+	docPath := flag.String("doc", "", "the path of the generated Markdown documentation (optional)")
+	flag.Parse()
+
+	if flag.NArg() != 1 || *packageName == "" {
+		fmt.Fprintln(os.Stderr, "usage: httperr-gen -package name [-o errors_gen.go] [-doc ERRORS.md] catalog.yaml")
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *packageName, *outputPath, *docPath); err != nil {
+		fmt.Fprintf(os.Stderr, "httperr-gen: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(catalogPath, packageName, outputPath, docPath string) error {
+	catalog, err := httperr.LoadCatalogFile(catalogPath)
+	if err != nil {
+		return err
+	}
+
+	src, err := generateGo(catalog, packageName, filepath.Base(catalogPath))
+	if err != nil {
+		return err
+	}
+	if outputPath == "" {
+		os.Stdout.Write(src)
+	} else if err := ioutil.WriteFile(outputPath, src, 0644); err != nil {
+		return err
+	}
+
+	if docPath != "" {
+		doc, err := generateMarkdown(catalog)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(docPath, doc, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errorData is the template data that describes a single error.
+type errorData struct {
+	httperr.Definition
+	Name   string   // the Go name of the error, e.g. UserNotFound
+	Format string   // the fmt format string of Message
+	Args   []string // the arguments to Format
+	Params []string // the parameters of the constructor, e.g. "name string"
+}
+
+func newErrorData(def httperr.Definition) (errorData, error) {
+	d := errorData{Definition: def, Name: goName(def.Code)}
+	if def.Message == "" {
+		return d, nil
+	}
+
+	format, args, err := def.MessageFormat()
+	if err != nil {
+		return d, fmt.Errorf("%s: %v", def.Code, err)
+	}
+	d.Format = format
+
+	// the names used in the body of the constructor cannot be parameters
+	reserved := map[string]bool{"fmt": true, "httperr": true, "err": true, "new" + d.Name: true}
+	paramNames := map[string]string{}
+	usedBy := map[string]string{}
+	for _, param := range def.Params {
+		n := goParamName(param.Name)
+		for reserved[n] {
+			n += "_"
+		}
+		if other, exists := usedBy[n]; exists {
+			return d, fmt.Errorf("%s: params %q and %q have the same Go name %s", def.Code, other, param.Name, n)
+		}
+		usedBy[n] = param.Name
+		paramNames[param.Name] = n
+		d.Params = append(d.Params, n+" "+param.Type)
+	}
+	for _, arg := range args {
+		d.Args = append(d.Args, paramNames[arg.Name])
+	}
+	return d, nil
+}
+
+var funcs = template.FuncMap{
+	"quote": strconv.Quote,
+	"join":  strings.Join,
+	"trim":  strings.TrimSpace,
+	"comment": func(s string) string {
+		return strings.Replace(strings.TrimSpace(s), "\n", "\n\t// ", -1)
+	},
+	"cell": func(s string) string {
+		s = strings.Replace(s, "|", `\|`, -1)
+		return strings.Join(strings.Fields(s), " ")
+	},
+}
+
+var goTemplate = template.Must(template.New("go").Funcs(funcs).Parse(`// Code generated by httperr-gen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- if .NeedsFmt}}
+	"fmt"
+{{- end}}
+
+	"github.com/crewjam/httperr"
+)
+
+var (
+{{- range .Errors}}
+	// {{.Name}} is the code of {{quote .Code}} errors.
+{{- if .Description}}
+	//
+	// {{comment .Description}}
+{{- end}}
+	{{.Name}} = httperr.RegisterCode({{quote .Code}}, {{.NumericCode}}, {{.StatusCode}})
+{{- end}}
+)
+{{range .Errors}}
+{{- if .Message}}
+// New{{.Name}} returns a new {{.Name}} error: {{quote .Message}}.
+func New{{.Name}}({{join .Params ", "}}) error {
+	return new{{.Name}}(fmt.Errorf({{quote .Format}}{{range .Args}}, {{.}}{{end}}))
+}
+{{- else}}
+// New{{.Name}} returns a new {{.Name}} error wrapping err.
+func New{{.Name}}(err error) error {
+	return new{{.Name}}(err)
+}
+{{- end}}
+
+func new{{.Name}}(err error) error {
+	return httperr.Value{
+		Err:         err,
+		StatusCode:  {{.StatusCode}},
+{{- if .Title}}
+		Title:       {{quote .Title}},
+{{- end}}
+		Public:      {{.Public}},
+		Code:        {{quote .Code}},
+		NumericCode: {{.NumericCode}},
+{{- if .DocURL}}
+		Type:        {{quote .DocURL}},
+{{- end}}
+	}
+}
+{{end}}`))
+
+func generateGo(catalog *httperr.Catalog, packageName, source string) ([]byte, error) {
+	data := struct {
+		Package  string
+		Source   string
+		NeedsFmt bool
+		Errors   []errorData
+	}{
+		Package: packageName,
+		Source:  source,
+	}
+	usedBy := map[string]string{} // the code that each generated identifier is for
+	for _, def := range catalog.Errors {
+		d, err := newErrorData(def)
+		if err != nil {
+			return nil, err
+		}
+		for _, ident := range []string{d.Name, "New" + d.Name, "new" + d.Name} {
+			if other, exists := usedBy[ident]; exists {
+				return nil, fmt.Errorf("%s: the Go name %s is already used by %s", def.Code, ident, other)
+			}
+			usedBy[ident] = def.Code
+		}
+		if d.Message != "" {
+			data.NeedsFmt = true
+		}
+		data.Errors = append(data.Errors, d)
+	}
+
+	var buf bytes.Buffer
+	if err := goTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("cannot format generated code: %v\n%s", err, buf.String())
+	}
+	return src, nil
+}
+
+var markdownTemplate = template.Must(template.New("md").Funcs(funcs).Parse(`# Errors
+
+| Code | Numeric code | Status | Title |
+| ---- | ------------ | ------ | ----- |
+{{- range .}}
+| [{{.Code}}](#{{.Code}}) | {{if .NumericCode}}{{.NumericCode}}{{end}} | {{.StatusCode}} | {{cell .Title}} |
+{{- end}}
+{{range .}}
+## {{.Code}}
+
+- **Status:** {{.StatusCode}}{{if .NumericCode}}
+- **Numeric code:** {{.NumericCode}}{{end}}{{if .Title}}
+- **Title:** {{.Title}}{{end}}{{if .Message}}
+- **Message:** ` + "`{{.Message}}`" + `{{end}}
+{{- if .Description}}
+
+{{trim .Description}}
+{{- end}}
+{{- if .DocURL}}
+
+See {{.DocURL}}
+{{- end}}
+{{end}}`))
+
+func generateMarkdown(catalog *httperr.Catalog) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := markdownTemplate.Execute(&buf, catalog.Errors); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// commonInitialisms are rendered in upper case in Go names.
+var commonInitialisms = map[string]bool{
+	"API": true, "HTTP": true, "ID": true, "IP": true, "JSON": true,
+	"SQL": true, "TLS": true, "URI": true, "URL": true, "UUID": true,
+}
+
+// goName returns the exported Go name of code, e.g. "user_not_found" becomes
+// "UserNotFound" and "invalid-user-id" becomes "InvalidUserID".
+func goName(code string) string {
+	var rv strings.Builder
+	words := strings.FieldsFunc(code, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if upper := strings.ToUpper(word); commonInitialisms[upper] {
+			rv.WriteString(upper)
+			continue
+		}
+		first, size := utf8.DecodeRuneInString(word)
+		rv.WriteRune(unicode.ToUpper(first))
+		rv.WriteString(word[size:])
+	}
+	if first, _ := utf8.DecodeRuneInString(rv.String()); rv.Len() == 0 || !unicode.IsUpper(first) {
+		return "Err" + rv.String()
+	}
+	return rv.String()
+}
+
+// goParamName returns the unexported Go name of a message parameter.
+func goParamName(name string) string {
+	n := goName(name)
+	for initialism := range commonInitialisms {
+		if strings.HasPrefix(n, initialism) {
+			n = strings.ToLower(initialism) + n[len(initialism):]
+			break
+		}
+	}
+	first, size := utf8.DecodeRuneInString(n)
+	n = string(unicode.ToLower(first)) + n[size:]
+	if token.IsKeyword(n) {
+		n += "_"
+	}
+	return n
+}
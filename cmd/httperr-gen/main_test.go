@@ -0,0 +1,119 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/crewjam/httperr"
+)
+
+// typeCheck reports an error if src, a generated Go file, does not compile.
+func typeCheck(t *testing.T, src []byte) {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "errors_gen.go", src, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	_, err = conf.Check("errs", fset, []*ast.File{file}, nil)
+	assert.NoError(t, err, "%s", src)
+}
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "errors_gen.go")
+	docPath := filepath.Join(dir, "ERRORS.md")
+	if !assert.NoError(t, run("testdata/errors.yaml", "errs", outputPath, docPath)) {
+		return
+	}
+
+	src, err := ioutil.ReadFile(outputPath)
+	assert.NoError(t, err)
+	typeCheck(t, src)
+
+	assert.Contains(t, string(src), "// Code generated by httperr-gen from errors.yaml. DO NOT EDIT.")
+	assert.Contains(t, string(src), `UserNotFound = httperr.RegisterCode("user_not_found", 1001, 404)`)
+	assert.Contains(t, string(src), "func NewUserNotFound(name string, userID int64) error {\n"+
+		"\treturn newUserNotFound(fmt.Errorf(\"no such user: %v (id %v, 100%% sure)\", name, userID))\n}")
+	assert.Contains(t, string(src), "func NewQuotaExceeded(err error) error {")
+	assert.Contains(t, string(src), `Type:        "https://example.com/docs/errors#user_not_found",`)
+
+	doc, err := ioutil.ReadFile(docPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(doc), "| [user_not_found](#user_not_found) | 1001 | 404 | User not found |")
+	assert.Contains(t, string(doc), "## quota_exceeded\n\n- **Status:** 429\n- **Title:** Quota exceeded\n")
+}
+
+func TestGoName(t *testing.T) {
+	assert.Equal(t, "UserNotFound", goName("user_not_found"))
+	assert.Equal(t, "InvalidUserID", goName("invalid-user-id"))
+	assert.Equal(t, "Err404", goName("404"))
+	assert.Equal(t, "userID", goParamName("user_id"))
+	assert.Equal(t, "idNumber", goParamName("id_number"))
+	assert.Equal(t, "type_", goParamName("type"))
+	assert.Equal(t, "ÉchecDeConnexion", goName("échec_de_connexion"))
+	assert.Equal(t, "Err错误", goName("错误"))
+	assert.Equal(t, "élément", goParamName("Élément"))
+}
+
+func TestGenerateNameCollisions(t *testing.T) {
+	generate := func(defs ...httperr.Definition) ([]byte, error) {
+		catalog, err := httperr.NewCatalog(defs...)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return generateGo(catalog, "errs", "errors.yaml")
+	}
+
+	// params that clash with the names used by the constructor are renamed
+	src, err := generate(httperr.Definition{
+		Code:       "user_not_found",
+		StatusCode: 404,
+		Message:    "{fmt} {httperr} {err} {new_user_not_found}",
+		Params: []httperr.Param{
+			{Name: "fmt", Type: "string"},
+			{Name: "httperr", Type: "string"},
+			{Name: "err", Type: "error"},
+			{Name: "new_user_not_found", Type: "int"},
+		},
+	})
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(src), "func NewUserNotFound(fmt_ string, httperr_ string, err_ error, newUserNotFound_ int) error {")
+		typeCheck(t, src)
+	}
+
+	// codes with the same Go name
+	_, err = generate(
+		httperr.Definition{Code: "user_not_found", StatusCode: 404},
+		httperr.Definition{Code: "user-not-found", StatusCode: 404},
+	)
+	assert.EqualError(t, err, "user-not-found: the Go name UserNotFound is already used by user_not_found")
+
+	// a code whose Go name is the constructor of another
+	_, err = generate(
+		httperr.Definition{Code: "user", StatusCode: 404},
+		httperr.Definition{Code: "new_user", StatusCode: 404},
+	)
+	assert.EqualError(t, err, "new_user: the Go name NewUser is already used by user")
+
+	// params with the same Go name
+	_, err = generate(httperr.Definition{
+		Code:       "user_not_found",
+		StatusCode: 404,
+		Message:    "{user_id} {user-id}",
+		Params: []httperr.Param{
+			{Name: "user_id", Type: "string"},
+			{Name: "user-id", Type: "string"},
+		},
+	})
+	assert.EqualError(t, err, `user_not_found: params "user_id" and "user-id" have the same Go name userID`)
+}
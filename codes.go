@@ -57,17 +57,25 @@ var (
 
 // New returns a new http error wrapping err with status statusCode.
 func New(statusCode int, err error) error {
-	return Value{
+	v := Value{
 		StatusCode: statusCode,
 		Err:        err,
 	}
+	if CaptureStackTraces {
+		v.stack = callers(1)
+	}
+	return v
 }
 
 // Public returns a new public http error wrapping err with status statusCode.
 func Public(statusCode int, err error) error {
-	return Value{
+	v := Value{
 		Public:     true,
 		StatusCode: statusCode,
 		Err:        err,
 	}
+	if CaptureStackTraces {
+		v.stack = callers(1)
+	}
+	return v
 }
@@ -0,0 +1,155 @@
+package httperr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// CaptureStackTraces controls whether New and Public record the stack trace
+// of the caller. The stack trace is printed when the error is formatted
+// with %+v and shown in debug mode. Capturing a stack trace is relatively
+// expensive, so it is disabled by default. It should be set before any
+// errors are created.
+var CaptureStackTraces = false
+
+// Debug enables debug mode, in which error responses include the private
+// details of errors: the full chain of wrapped errors and the stack trace.
+// It is intended for use in development only, and must never be enabled
+// for a server that is exposed to untrusted clients.
+var Debug = false
+
+// stack is a stack trace captured when an error was created
+type stack []uintptr
+
+func callers(skip int) *stack {
+	var pcs [32]uintptr
+	n := runtime.Callers(skip+2, pcs[:])
+	s := stack(pcs[:n])
+	return &s
+}
+
+// frames returns the stack trace as lines of the form "function file:line".
+func (s *stack) frames() []string {
+	if s == nil {
+		return nil
+	}
+	var rv []string
+	frames := runtime.CallersFrames(*s)
+	for {
+		frame, more := frames.Next()
+		rv = append(rv, fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line))
+		if !more {
+			return rv
+		}
+	}
+}
+
+// Format prints the stack trace in the same format as github.com/pkg/errors.
+func (s *stack) Format(st fmt.State, verb rune) {
+	for _, frame := range s.frames() {
+		i := strings.LastIndexByte(frame, ' ')
+		fmt.Fprintf(st, "\n%s\n\t%s", frame[:i], frame[i+1:])
+	}
+}
+
+// Format implements fmt.Formatter. The %+v verb prints the error followed by
+// the stack trace where the error was created, if one was captured, and the
+// detailed form of the underlying error. Like Error, it is not redacted.
+func (e Value) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, e.Error())
+			if e.stack != nil {
+				e.stack.Format(s, verb)
+			}
+			if _, ok := e.Err.(fmt.Formatter); ok {
+				io.WriteString(s, "\ncaused by: "+fmt.Sprintf("%+v", e.Err))
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+func (e Value) stackFrames() []string {
+	return e.stack.frames()
+}
+
+// DebugInfo describes the private details of an error. It is only included
+// in error responses in debug mode.
+type DebugInfo struct {
+	Chain []string `json:"chain"`           // the redacted text of each error in the chain, outermost first
+	Stack []string `json:"stack,omitempty"` // the stack trace where the error was created
+}
+
+type stackTracer interface {
+	StackTrace() pkgerrors.StackTrace
+}
+
+// newDebugInfo returns the debug information for err, following both
+// Unwrap and the Cause method used by github.com/pkg/errors. An error with
+// the same text as the error it wraps, such as the wrapper that
+// github.com/pkg/errors uses to attach a stack trace, is merged into the
+// entry of the wrapped error. The text of the errors is redacted as
+// appropriate for the response to r.
+func newDebugInfo(r *http.Request, err error) *DebugInfo {
+	rv := &DebugInfo{}
+	for err != nil {
+		next := errors.Unwrap(err)
+		if next == nil {
+			if causer, ok := err.(interface{ Cause() error }); ok {
+				next = causer.Cause()
+			}
+		}
+
+		if next == nil || next.Error() != err.Error() {
+			rv.Chain = append(rv.Chain, redactRequest(r, redact(err.Error())))
+		}
+
+		if rv.Stack == nil {
+			switch err := err.(type) {
+			case interface{ stackFrames() []string }:
+				rv.Stack = err.stackFrames()
+			case stackTracer:
+				for _, frame := range err.StackTrace() {
+					rv.Stack = append(rv.Stack, strings.Replace(fmt.Sprintf("%+v", frame), "\n\t", " ", 1))
+				}
+			}
+		}
+
+		err = next
+	}
+	return rv
+}
+
+// debugEnabled returns true if private error details should be included
+// in the response to r, either because Debug is set or because r carries
+// a valid debug token.
+func debugEnabled(r *http.Request) bool {
+	return Debug || hasValidDebugToken(r)
+}
+
+// debugRenderer renders problems in debug mode. It uses the built-in HTML
+// page, since custom templates may not show the debug information.
+var debugRenderer = ContentNegotiator{HTML: &HTMLRenderer{}}
+
+// writeDebug writes the debug version of a problem, which includes p.Debug,
+// to w as HTML, JSON or text depending on the Accept header of r.
+func writeDebug(w http.ResponseWriter, r *http.Request, p Problem) {
+	// debug responses must never be served to other clients from a cache
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Add("Vary", DebugTokenHeader)
+
+	debugRenderer.Render(w, r, p)
+}
@@ -0,0 +1,88 @@
+package httperr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStackTraces(t *testing.T) {
+	err := New(http.StatusConflict, fmt.Errorf("cannot frob the grob"))
+	assert.Equal(t, "409 Conflict: cannot frob the grob", fmt.Sprintf("%+v", err))
+
+	CaptureStackTraces = true
+	defer func() { CaptureStackTraces = false }()
+
+	err = New(http.StatusConflict, fmt.Errorf("cannot frob the grob"))
+	assert.Equal(t, "409 Conflict: cannot frob the grob", fmt.Sprintf("%v", err))
+	assert.Equal(t, "409 Conflict: cannot frob the grob", fmt.Sprintf("%s", err))
+
+	detail := fmt.Sprintf("%+v", err)
+	assert.True(t, strings.HasPrefix(detail, "409 Conflict: cannot frob the grob\n"+
+		"github.com/crewjam/httperr.TestStackTraces\n\t"), detail)
+	assert.Contains(t, detail, "debug_test.go:")
+
+	err = Public(http.StatusConflict, pkgerrors.New("cannot frob the grob"))
+	assert.Contains(t, fmt.Sprintf("%+v", err), "\ncaused by: cannot frob the grob\n")
+}
+
+func TestDebugMode(t *testing.T) {
+	h := HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+		return New(http.StatusConflict, pkgerrors.Wrap(fmt.Errorf("no rows"), "cannot frob the grob"))
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "Conflict\n", string(w.Body.Bytes()))
+
+	Debug = true
+	defer func() { Debug = false }()
+
+	t.Run("json", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", "application/json")
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, 409, w.Code)
+		var p Problem
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		assert.Equal(t, []string{
+			"409 Conflict: cannot frob the grob: no rows",
+			"cannot frob the grob: no rows",
+			"no rows",
+		}, p.Debug.Chain)
+		if assert.NotEmpty(t, p.Debug.Stack) {
+			assert.Contains(t, p.Debug.Stack[0], "github.com/crewjam/httperr.TestDebugMode")
+		}
+	})
+
+	t.Run("html", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", "text/html")
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, 409, w.Code)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, string(w.Body.Bytes()), "<li><pre>cannot frob the grob: no rows</pre></li>")
+		assert.Contains(t, string(w.Body.Bytes()), "<h2>Stack trace</h2>")
+	})
+
+	t.Run("text", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, 409, w.Code)
+		assert.True(t, strings.HasPrefix(string(w.Body.Bytes()), "409 Conflict\n\nerrors:\n"+
+			"  409 Conflict: cannot frob the grob: no rows\n"), string(w.Body.Bytes()))
+	})
+}
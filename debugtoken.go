@@ -0,0 +1,116 @@
+package httperr
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DebugTokenHeader is the request header that carries a debug token. A
+// request with a valid debug token gets a debug mode response that includes
+// the private details of the error, as if Debug were set.
+const DebugTokenHeader = "X-Debug-Token"
+
+const debugTokenVersion = "v1"
+
+// minDebugKeyLen is the length, in bytes, of the shortest key accepted by
+// SetDebugKeys.
+const minDebugKeyLen = 32
+
+var debugKeys = struct {
+	sync.RWMutex
+	keys [][]byte
+}{}
+
+// SetDebugKeys sets the HMAC keys that are used to verify debug tokens.
+// Tokens signed by any of the keys are accepted, which allows keys to be
+// rotated. Calling SetDebugKeys with no keys disables debug tokens, which is
+// the default. Keys must be at least 32 random bytes; SetDebugKeys panics
+// if any key is shorter.
+func SetDebugKeys(keys ...[]byte) {
+	for _, key := range keys {
+		if len(key) < minDebugKeyLen {
+			panic(fmt.Errorf("httperr: debug keys must be at least %d bytes", minDebugKeyLen))
+		}
+	}
+
+	debugKeys.Lock()
+	defer debugKeys.Unlock()
+	debugKeys.keys = nil
+	for _, key := range keys {
+		debugKeys.keys = append(debugKeys.keys, append([]byte(nil), key...))
+	}
+}
+
+// MaxDebugTokenTTL is the longest that a debug token may be valid for.
+// NewDebugToken shortens the lifetime of tokens to MaxDebugTokenTTL, and
+// VerifyDebugToken rejects tokens that expire later than MaxDebugTokenTTL
+// from now.
+var MaxDebugTokenTTL = time.Hour
+
+// NewDebugToken returns a debug token signed with key that is valid until
+// expires, or for MaxDebugTokenTTL if that is sooner. Tokens should be short
+// lived, since anyone who holds one can see the private details of errors.
+func NewDebugToken(key []byte, expires time.Time) string {
+	if latest := time.Now().Add(MaxDebugTokenTTL); expires.After(latest) {
+		expires = latest
+	}
+	payload := debugTokenVersion + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + signDebugToken(key, payload)
+}
+
+func signDebugToken(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyDebugToken returns nil if token was signed by one of the keys
+// passed to SetDebugKeys, has not expired, and does not expire later than
+// MaxDebugTokenTTL from now.
+func VerifyDebugToken(token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != debugTokenVersion {
+		return fmt.Errorf("malformed debug token")
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed debug token")
+	}
+
+	debugKeys.RLock()
+	keys := debugKeys.keys
+	debugKeys.RUnlock()
+
+	payload := parts[0] + "." + parts[1]
+	for _, key := range keys {
+		if hmac.Equal([]byte(signDebugToken(key, payload)), []byte(parts[2])) {
+			if now.Unix() >= expires {
+				return fmt.Errorf("debug token expired")
+			}
+			if expires > now.Add(MaxDebugTokenTTL).Unix() {
+				return fmt.Errorf("debug token lifetime exceeds the maximum")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid debug token signature")
+}
+
+// hasValidDebugToken returns true if r carries a valid debug token.
+func hasValidDebugToken(r *http.Request) bool {
+	if r == nil {
+		return false
+	}
+	token := r.Header.Get(DebugTokenHeader)
+	if token == "" {
+		return false
+	}
+	return VerifyDebugToken(token, time.Now()) == nil
+}
@@ -0,0 +1,101 @@
+package httperr
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugToken(t *testing.T) {
+	key := []byte("01234567890123456789012345678901")
+	oldKey := []byte("98765432109876543210987654321098")
+	now := time.Date(2020, 2, 18, 12, 0, 0, 0, time.UTC)
+
+	SetDebugKeys(key, oldKey)
+	defer SetDebugKeys()
+
+	token := NewDebugToken(key, now.Add(time.Minute))
+	assert.Equal(t, "v1.1582027260.", token[:14])
+	assert.NoError(t, VerifyDebugToken(token, now))
+	assert.NoError(t, VerifyDebugToken(NewDebugToken(oldKey, now.Add(time.Minute)), now))
+	assert.EqualError(t, VerifyDebugToken(token, now.Add(time.Minute)), "debug token expired")
+	assert.EqualError(t, VerifyDebugToken(NewDebugToken([]byte("wrong"), now.Add(time.Minute)), now),
+		"invalid debug token signature")
+	assert.EqualError(t, VerifyDebugToken("v1.1582027260", now), "malformed debug token")
+	assert.EqualError(t, VerifyDebugToken("v1.1582027270."+token[14:], now), "invalid debug token signature")
+
+	SetDebugKeys()
+	assert.EqualError(t, VerifyDebugToken(token, now), "invalid debug token signature")
+}
+
+func TestSetDebugKeysRejectsShortKeys(t *testing.T) {
+	key := []byte("01234567890123456789012345678901")
+	SetDebugKeys(key)
+	defer SetDebugKeys()
+
+	panicValue := func(f func()) (rv interface{}) {
+		defer func() { rv = recover() }()
+		f()
+		return nil
+	}
+	assert.EqualError(t, panicValue(func() { SetDebugKeys(key, []byte("short")) }).(error),
+		"httperr: debug keys must be at least 32 bytes")
+	assert.EqualError(t, panicValue(func() { SetDebugKeys([]byte{}) }).(error),
+		"httperr: debug keys must be at least 32 bytes")
+
+	// the keys are unchanged
+	assert.NoError(t, VerifyDebugToken(NewDebugToken(key, time.Now().Add(time.Minute)), time.Now()))
+}
+
+func TestDebugTokenMaxTTL(t *testing.T) {
+	key := []byte("01234567890123456789012345678901")
+	SetDebugKeys(key)
+	defer SetDebugKeys()
+
+	// tokens are issued for at most MaxDebugTokenTTL
+	now := time.Now()
+	token := NewDebugToken(key, now.Add(365*24*time.Hour))
+	assert.NoError(t, VerifyDebugToken(token, now))
+	assert.EqualError(t, VerifyDebugToken(token, now.Add(MaxDebugTokenTTL+time.Second)), "debug token expired")
+
+	// and tokens that expire later are rejected
+	payload := debugTokenVersion + "." + strconv.FormatInt(now.Add(365*24*time.Hour).Unix(), 10)
+	token = payload + "." + signDebugToken(key, payload)
+	assert.EqualError(t, VerifyDebugToken(token, now), "debug token lifetime exceeds the maximum")
+}
+
+func TestDebugTokenRevealsPrivateErrors(t *testing.T) {
+	key := []byte("01234567890123456789012345678901")
+	SetDebugKeys(key)
+	defer SetDebugKeys()
+
+	h := HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+		return fmt.Errorf("cannot frob the grob")
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "Internal Server Error\n", string(w.Body.Bytes()))
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Debug-Token", NewDebugToken(key, time.Now().Add(-time.Second)))
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "Internal Server Error\n", string(w.Body.Bytes()))
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Debug-Token", NewDebugToken(key, time.Now().Add(time.Minute)))
+	h.ServeHTTP(w, r)
+	assert.Equal(t, 500, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "500 Internal Server Error\n\nerrors:\n"+
+		"  500 Internal Server Error: cannot frob the grob\n"+
+		"  cannot frob the grob\n", string(w.Body.Bytes()))
+}
@@ -0,0 +1,174 @@
+package httperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// Code is an application error code: a stable, machine readable identifier
+// for a class of error that clients can switch on, such as "user_not_found".
+// Codes are defined once with RegisterCode and matched with errors.Is:
+//
+//	var UserNotFound = httperr.RegisterCode("user_not_found", 1001, http.StatusNotFound)
+//
+//	func (s *Server) getUser(w http.ResponseWriter, r *http.Request) error {
+//	    user, err := s.Storage.Get(name)
+//	    if err == storage.ErrNotFound {
+//	        return UserNotFound.Public(err)
+//	    }
+//	    // ...
+//	}
+//
+//	if errors.Is(err, UserNotFound) {
+//	    // ...
+//	}
+type Code struct {
+	Name       string // the string form of the code, e.g. "user_not_found"
+	Number     int    // the numeric form of the code (optional)
+	StatusCode int    // the HTTP status code of errors with this code
+}
+
+// New returns a new http error with code c wrapping err.
+func (c Code) New(err error) error {
+	v := c.value()
+	v.Err = err
+	if CaptureStackTraces {
+		v.stack = callers(1)
+	}
+	return v
+}
+
+// Public returns a new public http error with code c wrapping err.
+func (c Code) Public(err error) error {
+	v := c.value()
+	v.Public = true
+	v.Err = err
+	if CaptureStackTraces {
+		v.stack = callers(1)
+	}
+	return v
+}
+
+func (c Code) value() Value {
+	return Value{
+		StatusCode:  c.StatusCode,
+		Code:        c.Name,
+		NumericCode: c.Number,
+	}
+}
+
+func (c Code) Error() string {
+	return c.Name
+}
+
+// ErrorCode returns the string and numeric forms of the code.
+func (c Code) ErrorCode() (string, int) {
+	return c.Name, c.Number
+}
+
+// StatusCodeAndText returns the status code and text of the error
+func (c Code) StatusCodeAndText() (int, string) {
+	return c.value().StatusCodeAndText()
+}
+
+// WriteError writes an error response to w using the status code of c.
+func (c Code) WriteError(w http.ResponseWriter, r *http.Request) {
+	c.value().WriteError(w, r)
+}
+
+var _ error = Code{}
+var _ Writer = Code{}
+var _ errorCoder = Code{}
+var _ statusCodeAndTexter = Code{}
+
+var codeRegistry = struct {
+	sync.Mutex
+	byName   map[string]Code
+	byNumber map[int]Code
+}{
+	byName:   map[string]Code{},
+	byNumber: map[int]Code{},
+}
+
+// RegisterCode defines a new application error code. It panics if name is
+// empty or if either name or number (when non-zero) is already registered.
+// It is intended to be called when initializing package level variables.
+func RegisterCode(name string, number int, statusCode int) Code {
+	c := Code{Name: name, Number: number, StatusCode: statusCode}
+	if err := registerCode(c); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func registerCode(c Code) error {
+	codeRegistry.Lock()
+	defer codeRegistry.Unlock()
+
+	if c.Name == "" {
+		return fmt.Errorf("httperr: error code must have a name")
+	}
+	if _, exists := codeRegistry.byName[c.Name]; exists {
+		return fmt.Errorf("httperr: error code %q is already registered", c.Name)
+	}
+	if other, exists := codeRegistry.byNumber[c.Number]; exists && c.Number != 0 {
+		return fmt.Errorf("httperr: error code %q: number %d is already used by %q", c.Name, c.Number, other.Name)
+	}
+
+	codeRegistry.byName[c.Name] = c
+	if c.Number != 0 {
+		codeRegistry.byNumber[c.Number] = c
+	}
+	return nil
+}
+
+// LookupCode returns the registered code with the specified name.
+func LookupCode(name string) (Code, bool) {
+	codeRegistry.Lock()
+	defer codeRegistry.Unlock()
+	c, ok := codeRegistry.byName[name]
+	return c, ok
+}
+
+// Codes returns all the registered codes, sorted by name.
+func Codes() []Code {
+	codeRegistry.Lock()
+	defer codeRegistry.Unlock()
+	rv := make([]Code, 0, len(codeRegistry.byName))
+	for _, c := range codeRegistry.byName {
+		rv = append(rv, c)
+	}
+	sort.Slice(rv, func(i, j int) bool { return rv[i].Name < rv[j].Name })
+	return rv
+}
+
+type errorCoder interface {
+	ErrorCode() (string, int)
+}
+
+// ErrorCode returns the application error code of err in both string and
+// numeric forms. If err does not carry a code, the string form is empty.
+func ErrorCode(err error) (string, int) {
+	if err == nil {
+		return "", 0
+	}
+
+	err = pkgerrors.Cause(err)
+
+	var coder errorCoder
+	if errors.As(err, &coder) {
+		return coder.ErrorCode()
+	}
+	return "", 0
+}
+
+// isCode returns true if target is a Code with the specified name.
+func isCode(name string, target error) bool {
+	c, ok := target.(Code)
+	return ok && name != "" && c.Name == name
+}
@@ -0,0 +1,124 @@
+package httperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testUserNotFound  = RegisterCode("test_user_not_found", 91001, http.StatusNotFound)
+	testQuotaExceeded = RegisterCode("test_quota_exceeded", 0, http.StatusTooManyRequests)
+)
+
+func TestRegisterCode(t *testing.T) {
+	c, ok := LookupCode("test_user_not_found")
+	assert.True(t, ok)
+	assert.Equal(t, testUserNotFound, c)
+	assert.Contains(t, Codes(), testQuotaExceeded)
+
+	panicValue := func(f func()) (rv interface{}) {
+		defer func() { rv = recover() }()
+		f()
+		return nil
+	}
+	assert.EqualError(t, panicValue(func() { RegisterCode("test_user_not_found", 0, 404) }).(error),
+		`httperr: error code "test_user_not_found" is already registered`)
+	assert.EqualError(t, panicValue(func() { RegisterCode("test_other", 91001, 404) }).(error),
+		`httperr: error code "test_other": number 91001 is already used by "test_user_not_found"`)
+	_, ok = LookupCode("test_other")
+	assert.False(t, ok)
+}
+
+func TestErrorCodeIs(t *testing.T) {
+	err := testUserNotFound.Public(errors.New("no such user: alice"))
+	assert.True(t, errors.Is(err, testUserNotFound))
+	assert.False(t, errors.Is(err, testQuotaExceeded))
+	assert.True(t, errors.Is(fmt.Errorf("get user: %w", err), testUserNotFound))
+
+	code, number := ErrorCode(fmt.Errorf("get user: %w", err))
+	assert.Equal(t, "test_user_not_found", code)
+	assert.Equal(t, 91001, number)
+
+	code, _ = ErrorCode(pkgerrors.Wrap(err, "get user"))
+	assert.Equal(t, "test_user_not_found", code)
+
+	code, _ = ErrorCode(errors.New("plain"))
+	assert.Equal(t, "", code)
+
+	statusCode, _ := StatusCodeAndText(err)
+	assert.Equal(t, 404, statusCode)
+}
+
+func TestErrorCodeWriteError(t *testing.T) {
+	h := HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+		return testUserNotFound.Public(errors.New("no such user: alice"))
+	})
+
+	t.Run("text", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, 404, w.Code)
+		assert.Equal(t, "test_user_not_found", w.Header().Get("X-Error-Code"))
+		assert.Equal(t, "no such user: alice\n", string(w.Body.Bytes()))
+	})
+
+	t.Run("problem", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", "text/html;q=0.9, application/json")
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, 404, w.Code)
+		assert.Equal(t, "test_user_not_found", w.Header().Get("X-Error-Code"))
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"title": "Not Found", "status": 404, "detail": "no such user: alice",
+			"code": "test_user_not_found", "numeric_code": 91001}`, string(w.Body.Bytes()))
+	})
+
+	t.Run("private problem", func(t *testing.T) {
+		h := HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+			return testQuotaExceeded.New(errors.New("secret"))
+		})
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", "application/problem+json")
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, 429, w.Code)
+		assert.JSONEq(t, `{"title": "Too Many Requests", "status": 429,
+			"code": "test_quota_exceeded"}`, string(w.Body.Bytes()))
+	})
+}
+
+func TestClientErrorCode(t *testing.T) {
+	server := httptest.NewServer(HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+		return testUserNotFound.Public(errors.New("no such user: alice"))
+	}))
+	defer server.Close()
+
+	t.Run("response", func(t *testing.T) {
+		_, err := Client(server.Client()).Get(server.URL)
+		assert.True(t, errors.Is(err, testUserNotFound))
+	})
+
+	t.Run("problem", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Header.Set("Accept", "application/problem+json")
+		_, err := Client(server.Client(), JSON(Problem{})).Do(req)
+		assert.True(t, errors.Is(err, testUserNotFound))
+
+		var problem Problem
+		if assert.True(t, errors.As(err, &problem)) {
+			assert.Equal(t, "no such user: alice", problem.Detail)
+			assert.Equal(t, 91001, problem.NumericCode)
+		}
+	})
+}
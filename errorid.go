@@ -0,0 +1,80 @@
+package httperr
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// RequestIDHeader is the request header from which Middleware adopts
+	// error IDs.
+	RequestIDHeader = "X-Request-ID"
+
+	// ErrorIDHeader is the response header in which Middleware sends error IDs.
+	ErrorIDHeader = "X-Error-ID"
+)
+
+// ErrorWithID is an error that Middleware has assigned a correlation ID.
+// The ID is also sent to the client, which lets support staff find the
+// server log entry for an error that a user reports.
+type ErrorWithID struct {
+	Err error
+	ID  string
+}
+
+func (e ErrorWithID) Error() string {
+	if e.Err == nil {
+		return "error id: " + e.ID
+	}
+	return fmt.Sprintf("%s (error id: %s)", e.Err.Error(), e.ID)
+}
+
+// Unwrap returns the underlying error
+func (e ErrorWithID) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns the underlying error, so that pkgerrors.Cause, and
+// therefore Write and StatusCodeAndText, see through the ID to errors
+// wrapped by github.com/pkg/errors.
+func (e ErrorWithID) Cause() error {
+	return e.Err
+}
+
+// ErrorID returns the correlation ID that Middleware assigned to the failed
+// request r, or an empty string if none has been assigned.
+func ErrorID(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	if state, ok := r.Context().Value(requestStateIndex).(*requestState); ok {
+		return state.errorID
+	}
+	return ""
+}
+
+// RandomErrorID returns a random 128-bit ID as a hex string. It is suitable
+// for use as Middleware.NewErrorID.
+func RandomErrorID(r *http.Request) string {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf[:])
+}
+
+// validErrorID returns true if id, which is supplied by the client, is
+// safe to adopt as an error ID.
+func validErrorID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' || id[i] == '"' || id[i] == '<' || id[i] == '>' {
+			return false
+		}
+	}
+	return true
+}
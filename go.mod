@@ -1,8 +1,9 @@
 module github.com/crewjam/httperr
 
-go 1.13
+go 1.16
 
 require (
 	github.com/pkg/errors v0.8.1
 	github.com/stretchr/testify v1.4.0
+	gopkg.in/yaml.v2 v2.2.2
 )
@@ -28,6 +28,21 @@ func StatusCodeAndText(err error) (int, string) {
 	return http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
 }
 
+// ErrorStatus returns the status code and text of err like
+// StatusCodeAndText, except that a Response, such as one returned by a
+// Transport or captured by Middleware from a handler that wrote an error
+// response itself, has the status code it was sent with.
+func ErrorStatus(err error) (int, string) {
+	cause := pkgerrors.Cause(err)
+
+	var scater statusCodeAndTexter
+	var re Response
+	if !errors.As(cause, &scater) && errors.As(cause, &re) {
+		return re.StatusCode, re.Error()
+	}
+	return StatusCodeAndText(err)
+}
+
 // Writer is an interface for things that know how to write themselves
 // to an error response. This interface is implemented by Private and
 // Public to provide default error pages.
@@ -0,0 +1,185 @@
+package httperrtest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Fault describes the outcome of a request made through a FaultTransport.
+// If neither StatusCode nor Err is set, the request is passed to the Next
+// transport, after Delay.
+type Fault struct {
+	StatusCode int         // the status code of the response
+	Header     http.Header // the headers of the response (optional)
+	Body       string      // the body of the response (optional)
+
+	// Truncate, if true, causes reading the body to fail with
+	// io.ErrUnexpectedEOF after Body has been read, as when a connection is
+	// closed part way through a response.
+	Truncate bool
+
+	// Delay is how long to wait before responding. If the context of the
+	// request is canceled first, the request fails with the context's error.
+	Delay time.Duration
+
+	// Err, if not nil, is returned instead of a response, as for a
+	// network error.
+	Err error
+}
+
+// JSON returns a Fault that responds with statusCode and body as application/json.
+func JSON(statusCode int, body string) Fault {
+	return Fault{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       body,
+	}
+}
+
+// MalformedJSON returns a Fault that responds with statusCode and a body
+// that claims to be, but is not, valid JSON.
+func MalformedJSON(statusCode int) Fault {
+	return JSON(statusCode, `{"message": "cannot frob the gr`)
+}
+
+// Network errors
+var (
+	ConnectionRefused = Fault{Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}
+	ConnectionReset   = Fault{Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}
+	Timeout           = Fault{Err: &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}}
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// FaultTransport is an http.RoundTripper that responds to requests with
+// scripted faults, so that error handling can be tested deterministically
+// and without a network. It is typically used as the Next transport of an
+// httperr.Transport:
+//
+//	faults := httperrtest.NewFaultTransport(
+//	    httperrtest.ConnectionReset,
+//	    httperrtest.JSON(http.StatusServiceUnavailable, `{"message": "try again"}`),
+//	    httperrtest.Fault{StatusCode: http.StatusOK, Body: "ok"},
+//	)
+//	client := httperr.Client(&http.Client{Transport: faults}, httperr.JSON(APIError{}))
+type FaultTransport struct {
+	// Next handles requests that are not faulted. If nil, such requests
+	// fail with an error, which keeps tests offline.
+	Next http.RoundTripper
+
+	// ForRequest, if not nil, returns the fault for a request. If it returns
+	// false, the next fault of the sequence is used.
+	ForRequest func(r *http.Request) (Fault, bool)
+
+	mu       sync.Mutex
+	sequence []Fault
+	requests int
+}
+
+// NewFaultTransport returns a FaultTransport that responds to the first
+// request with the first fault, the second request with the second fault,
+// and so on. Once the sequence is exhausted, requests are passed to Next.
+func NewFaultTransport(sequence ...Fault) *FaultTransport {
+	return &FaultTransport{sequence: sequence}
+}
+
+// Requests returns the number of requests that have been made.
+func (ft *FaultTransport) Requests() int {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	return ft.requests
+}
+
+// fault returns the fault for r.
+func (ft *FaultTransport) fault(r *http.Request) Fault {
+	if ft.ForRequest != nil {
+		if fault, ok := ft.ForRequest(r); ok {
+			ft.mu.Lock()
+			ft.requests++
+			ft.mu.Unlock()
+			return fault
+		}
+	}
+
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	ft.requests++
+	if len(ft.sequence) == 0 {
+		return Fault{}
+	}
+	fault := ft.sequence[0]
+	ft.sequence = ft.sequence[1:]
+	return fault
+}
+
+// RoundTrip implements http.RoundTripper.
+func (ft *FaultTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	fault := ft.fault(r)
+
+	if fault.Delay > 0 {
+		timer := time.NewTimer(fault.Delay)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return nil, r.Context().Err()
+		}
+	}
+
+	if fault.Err != nil {
+		return nil, fault.Err
+	}
+	if fault.StatusCode == 0 {
+		if ft.Next == nil {
+			return nil, fmt.Errorf("httperrtest: no fault for %s %s and no Next transport", r.Method, r.URL)
+		}
+		return ft.Next.RoundTrip(r)
+	}
+
+	if r.Body != nil {
+		r.Body.Close()
+	}
+
+	header := http.Header{}
+	for key, values := range fault.Header {
+		header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+	var body io.Reader = strings.NewReader(fault.Body)
+	contentLength := int64(len(fault.Body))
+	if fault.Truncate {
+		body = io.MultiReader(body, truncatedReader{})
+		contentLength = -1
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fault.StatusCode, http.StatusText(fault.StatusCode)),
+		StatusCode:    fault.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(body),
+		ContentLength: contentLength,
+		Request:       r,
+	}, nil
+}
+
+type truncatedReader struct{}
+
+func (truncatedReader) Read([]byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
+
+var _ http.RoundTripper = &FaultTransport{}
@@ -0,0 +1,125 @@
+package httperrtest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/crewjam/httperr"
+	"github.com/stretchr/testify/assert"
+)
+
+type apiError struct {
+	Message string `json:"message"`
+}
+
+func (e apiError) Error() string {
+	return e.Message
+}
+
+func TestFaultTransportSequence(t *testing.T) {
+	faults := NewFaultTransport(
+		ConnectionReset,
+		JSON(http.StatusServiceUnavailable, `{"message": "try again"}`),
+		MalformedJSON(http.StatusBadGateway),
+		Fault{StatusCode: http.StatusOK, Body: "ok"},
+	)
+	faults.Next = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusTeapot, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})
+	client := httperr.Client(&http.Client{Transport: faults}, httperr.JSON(apiError{}))
+
+	_, err := client.Get("http://example.com/")
+	assert.True(t, errors.Is(err, syscall.ECONNRESET), err)
+
+	_, err = client.Get("http://example.com/")
+	var e apiError
+	if AssertErrorAs(t, err, &e) {
+		assert.Equal(t, "try again", e.Message)
+	}
+
+	_, err = client.Get("http://example.com/")
+	AssertError(t, err, Expect{StatusCode: http.StatusBadGateway, Message: "502 Bad Gateway"})
+
+	resp, err := client.Get("http://example.com/")
+	if assert.NoError(t, err) {
+		body, _ := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, "ok", string(body))
+		assert.Equal(t, int64(2), resp.ContentLength)
+	}
+
+	// the sequence is exhausted, so requests go to Next
+	_, err = client.Get("http://example.com/")
+	AssertError(t, err, Expect{StatusCode: http.StatusTeapot})
+	assert.Equal(t, 5, faults.Requests())
+}
+
+func TestFaultTransportForRequest(t *testing.T) {
+	faults := NewFaultTransport(Timeout)
+	faults.ForRequest = func(r *http.Request) (Fault, bool) {
+		if r.URL.Path == "/missing" {
+			return Fault{StatusCode: http.StatusNotFound, Header: http.Header{"x-error-code": {"not_found"}}}, true
+		}
+		return Fault{}, false
+	}
+	client := httperr.Client(&http.Client{Transport: faults})
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Get("http://example.com/missing")
+		AssertError(t, err, Expect{StatusCode: http.StatusNotFound, Code: "not_found"})
+	}
+
+	_, err := client.Get("http://example.com/")
+	var netErr net.Error
+	if AssertErrorAs(t, err, &netErr) {
+		assert.True(t, netErr.Timeout())
+	}
+
+	// without Next, unscripted requests fail rather than reaching the network
+	_, err = client.Get("http://example.com/")
+	assert.EqualError(t, err, `Get "http://example.com/": httperrtest: no fault for GET http://example.com/ and no Next transport`)
+}
+
+func TestFaultTransportTruncate(t *testing.T) {
+	faults := NewFaultTransport(
+		Fault{StatusCode: http.StatusBadRequest, Body: `{"message": "canno`, Truncate: true},
+		Fault{StatusCode: http.StatusOK, Body: "partial", Truncate: true},
+	)
+	client := httperr.Client(&http.Client{Transport: faults}, httperr.JSON(apiError{}))
+
+	_, err := client.Get("http://example.com/")
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), err)
+
+	resp, err := client.Get("http://example.com/")
+	if assert.NoError(t, err) {
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, "partial", string(body))
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+	}
+}
+
+func TestFaultTransportDelay(t *testing.T) {
+	faults := NewFaultTransport(
+		Fault{StatusCode: http.StatusOK, Delay: time.Millisecond},
+		Fault{StatusCode: http.StatusOK, Delay: time.Hour},
+	)
+	client := &http.Client{Transport: faults}
+
+	start := time.Now()
+	_, err := client.Get("http://example.com/")
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com/", nil)
+	_, err = client.Do(req)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+}
@@ -0,0 +1,213 @@
+// Package httperrtest provides utilities for testing code that uses httperr.
+//
+// On the server side, ServeError invokes a handler and checks the error
+// response it writes:
+//
+//	func TestGetUser(t *testing.T) {
+//	    r := httptest.NewRequest("GET", "/users/alice", nil)
+//	    httperrtest.ServeError(t, server, r, httperrtest.Expect{
+//	        StatusCode: http.StatusNotFound,
+//	        Code:       "user_not_found",
+//	    })
+//	}
+//
+// On the client side, AssertError checks an error returned by an
+// http.Client, looking through *url.Error and other wrappers:
+//
+//	_, err := client.Get(url)
+//	httperrtest.AssertError(t, err, httperrtest.Expect{StatusCode: http.StatusNotFound})
+//
+// FaultTransport injects errors into an http.Client, to test how the client
+// handles them, and Recorder and Replayer capture real error responses
+// and serve them back in tests that run offline. MockServer serves canned
+// errors over HTTP.
+package httperrtest
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/crewjam/httperr"
+	pkgerrors "github.com/pkg/errors"
+)
+
+// Expect describes an expected error. Fields that are zero are not checked.
+type Expect struct {
+	StatusCode int    // the HTTP status code
+	Code       string // the application error code
+	Message    string // the message revealed to the client
+
+	// Header contains headers that must be present in the response with
+	// exactly the values given. Checked only by ServeError and AssertResponse.
+	Header http.Header
+
+	// Problem, if not nil, is compared with the problem document in the body
+	// of the response, or with the Problem in the error chain. Only fields of
+	// Problem that are not zero are compared.
+	Problem *httperr.Problem
+}
+
+// ServeError invokes h, which may be an httperr.HandlerFunc or an
+// httperr.Middleware, with r and checks that the response matches expect.
+// It returns the recorded response so that the caller can make further
+// assertions.
+func ServeError(t testing.TB, h http.Handler, r *http.Request, expect Expect) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	AssertResponse(t, w, expect)
+	return w
+}
+
+// AssertResponse checks that the recorded response w matches expect. The
+// message is the detail of the problem, or its title if there is no
+// detail, for JSON responses, and the body without the error ID for text
+// responses. AssertResponse returns true if the response matches.
+func AssertResponse(t testing.TB, w *httptest.ResponseRecorder, expect Expect) bool {
+	t.Helper()
+	ok := true
+
+	if expect.StatusCode != 0 && w.Code != expect.StatusCode {
+		t.Errorf("status code: expected %d, got %d", expect.StatusCode, w.Code)
+		ok = false
+	}
+	if expect.Code != "" && w.Header().Get(httperr.ErrorCodeHeader) != expect.Code {
+		t.Errorf("error code: expected %q, got %q", expect.Code, w.Header().Get(httperr.ErrorCodeHeader))
+		ok = false
+	}
+	for key, values := range expect.Header {
+		if actual := w.Header()[http.CanonicalHeaderKey(key)]; !reflect.DeepEqual(actual, values) {
+			t.Errorf("header %s: expected %q, got %q", key, values, actual)
+			ok = false
+		}
+	}
+
+	var problem *httperr.Problem
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if mediaType == "application/problem+json" || mediaType == "application/json" {
+		problem = &httperr.Problem{}
+		if err := json.Unmarshal(w.Body.Bytes(), problem); err != nil {
+			t.Errorf("cannot decode problem: %v", err)
+			return false
+		}
+	}
+
+	if expect.Message != "" {
+		var message string
+		if problem != nil {
+			message = problem.Error()
+		} else {
+			message = strings.TrimSuffix(w.Body.String(), "\n")
+			if i := strings.LastIndex(message, " (error id: "); i >= 0 {
+				message = message[:i]
+			}
+		}
+		if message != expect.Message {
+			t.Errorf("message: expected %q, got %q", expect.Message, message)
+			ok = false
+		}
+	}
+
+	if expect.Problem != nil {
+		if problem == nil {
+			t.Errorf("problem: expected a problem document, got %s", w.Header().Get("Content-Type"))
+			return false
+		}
+		if !matchProblem(t, *expect.Problem, *problem) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// AssertError checks that err, which is typically returned by an http.Client
+// that uses an httperr.Transport, matches expect. The status code and
+// application error code are found with httperr.ErrorStatus and
+// httperr.ErrorCode, and the message is the text of the error that
+// provides them. Header is not checked. AssertError returns true if err
+// matches.
+func AssertError(t testing.TB, err error, expect Expect) bool {
+	t.Helper()
+	if err == nil {
+		t.Errorf("expected an error, got nil")
+		return false
+	}
+	ok := true
+
+	statusCode, message := httperr.ErrorStatus(err)
+	if expect.StatusCode != 0 && statusCode != expect.StatusCode {
+		t.Errorf("status code: expected %d, got %d (%v)", expect.StatusCode, statusCode, err)
+		ok = false
+	}
+	if code, _ := httperr.ErrorCode(err); expect.Code != "" && code != expect.Code {
+		t.Errorf("error code: expected %q, got %q (%v)", expect.Code, code, err)
+		ok = false
+	}
+
+	var problem httperr.Problem
+	hasProblem := errors.As(pkgerrors.Cause(err), &problem)
+	if hasProblem {
+		message = problem.Error()
+	}
+	if expect.Message != "" && message != expect.Message {
+		t.Errorf("message: expected %q, got %q", expect.Message, message)
+		ok = false
+	}
+
+	if expect.Problem != nil {
+		if !hasProblem {
+			t.Errorf("problem: expected a Problem in the error chain, got %T (%v)", err, err)
+			return false
+		}
+		if !matchProblem(t, *expect.Problem, problem) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// AssertErrorAs checks that there is an error in the chain of err that can
+// be assigned to target, which must be a non-nil pointer, as in errors.As.
+// Unlike errors.As it also follows the Cause method of errors from
+// github.com/pkg/errors. AssertErrorAs returns true if one is found.
+func AssertErrorAs(t testing.TB, err error, target interface{}) bool {
+	t.Helper()
+	if err == nil {
+		t.Errorf("expected an error, got nil")
+		return false
+	}
+	if errors.As(err, target) || errors.As(pkgerrors.Cause(err), target) {
+		return true
+	}
+	t.Errorf("expected an error of type %s in the chain, got %T (%v)",
+		reflect.TypeOf(target).Elem(), err, err)
+	return false
+}
+
+// matchProblem reports fields of actual that do not match the non-zero
+// fields of expected.
+func matchProblem(t testing.TB, expected, actual httperr.Problem) bool {
+	t.Helper()
+	ok := true
+	check := func(name string, expected, actual interface{}) {
+		if !reflect.ValueOf(expected).IsZero() && expected != actual {
+			t.Errorf("problem %s: expected %#v, got %#v", name, expected, actual)
+			ok = false
+		}
+	}
+	check("type", expected.Type, actual.Type)
+	check("title", expected.Title, actual.Title)
+	check("status", expected.Status, actual.Status)
+	check("detail", expected.Detail, actual.Detail)
+	check("instance", expected.Instance, actual.Instance)
+	check("code", expected.Code, actual.Code)
+	check("numeric_code", expected.NumericCode, actual.NumericCode)
+	check("error_id", expected.ErrorID, actual.ErrorID)
+	return ok
+}
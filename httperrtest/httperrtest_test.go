@@ -0,0 +1,127 @@
+package httperrtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/crewjam/httperr"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeT records the failures reported by the assertion helpers
+type fakeT struct {
+	testing.TB
+	failures []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var handler = httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+	return httperr.Value{
+		StatusCode: http.StatusConflict,
+		Err:        fmt.Errorf("cannot frob the grob"),
+		Public:     true,
+		Code:       "grob_frobbed",
+		Header:     http.Header{"Retry-After": {"30"}},
+	}
+})
+
+func TestServeError(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	ServeError(t, handler, r, Expect{
+		StatusCode: http.StatusConflict,
+		Code:       "grob_frobbed",
+		Message:    "cannot frob the grob",
+		Header:     http.Header{"Retry-After": {"30"}},
+	})
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/json")
+	ServeError(t, handler, r, Expect{
+		Message: "cannot frob the grob",
+		Problem: &httperr.Problem{Status: http.StatusConflict, Title: "Conflict", Code: "grob_frobbed"},
+	})
+
+	ft := &fakeT{}
+	r = httptest.NewRequest("GET", "/", nil)
+	ok := AssertResponse(ft, ServeError(ft, handler, r, Expect{}), Expect{
+		StatusCode: http.StatusNotFound,
+		Code:       "not_found",
+		Message:    "cannot find the grob",
+		Header:     http.Header{"Retry-After": {"60"}},
+		Problem:    &httperr.Problem{},
+	})
+	assert.False(t, ok)
+	assert.Equal(t, []string{
+		"status code: expected 404, got 409",
+		`error code: expected "not_found", got "grob_frobbed"`,
+		`header Retry-After: expected ["60"], got ["30"]`,
+		`message: expected "cannot find the grob", got "cannot frob the grob"`,
+		"problem: expected a problem document, got text/plain; charset=utf-8",
+	}, ft.failures)
+}
+
+func TestServeErrorWithID(t *testing.T) {
+	h := httperr.Middleware{NewErrorID: httperr.RandomErrorID, Handler: handler}
+	r := httptest.NewRequest("GET", "/", nil)
+	ServeError(t, h, r, Expect{Message: "cannot frob the grob"})
+}
+
+func TestAssertError(t *testing.T) {
+	client := httperr.Client(&http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusConflict,
+			Header: http.Header{
+				"Content-Type":          {"application/problem+json"},
+				httperr.ErrorCodeHeader: {"grob_frobbed"},
+			},
+			Body: ioutil.NopCloser(strings.NewReader(`{"status": 409, "title": "Conflict", "detail": "cannot frob the grob", "code": "grob_frobbed"}`)),
+		}, nil
+	})}, httperr.JSON(httperr.Problem{}))
+
+	_, err := client.Get("http://example.com/")
+	AssertError(t, err, Expect{
+		StatusCode: http.StatusConflict,
+		Code:       "grob_frobbed",
+		Message:    "cannot frob the grob",
+		Problem:    &httperr.Problem{Detail: "cannot frob the grob"},
+	})
+
+	var problem httperr.Problem
+	if AssertErrorAs(t, err, &problem) {
+		assert.Equal(t, "cannot frob the grob", problem.Detail)
+	}
+
+	ft := &fakeT{}
+	assert.False(t, AssertError(ft, err, Expect{
+		StatusCode: http.StatusNotFound,
+		Problem:    &httperr.Problem{Detail: "cannot find the grob"},
+	}))
+	assert.Equal(t, []string{
+		`status code: expected 404, got 409 (Get "http://example.com/": cannot frob the grob)`,
+		`problem detail: expected "cannot find the grob", got "cannot frob the grob"`,
+	}, ft.failures)
+
+	ft = &fakeT{}
+	assert.False(t, AssertError(ft, nil, Expect{}))
+	var response httperr.Response
+	assert.False(t, AssertErrorAs(ft, fmt.Errorf("oops"), &response))
+	assert.Equal(t, []string{
+		"expected an error, got nil",
+		"expected an error of type httperr.Response in the chain, got *errors.errorString (oops)",
+	}, ft.failures)
+}
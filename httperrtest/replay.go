@@ -0,0 +1,310 @@
+package httperrtest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Fixture is the format of the file in which a Recorder saves error
+// responses, and from which a Replayer serves them.
+type Fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and the error response to it.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request in a Fixture. Requests are matched by Method,
+// Path and BodySHA256. Header is for reference only.
+type RecordedRequest struct {
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	Header     http.Header `json:"header,omitempty"`
+	BodySHA256 string      `json:"body_sha256"`
+}
+
+// RecordedResponse is a response in a Fixture.
+type RecordedResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// DefaultRedactedHeaders are the headers whose values a Recorder replaces
+// with "[REDACTED]" if RedactHeaders is nil.
+var DefaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+// Recorder is an http.RoundTripper that records the error responses, those
+// with status codes >= 400, returned by Next to a fixture file, so that a
+// Replayer can serve them to tests that run without network access. The
+// fixture is written after each recorded response. If the fixture file
+// already exists, requests fail unless Overwrite is set, so that fixtures
+// that are checked in are not replaced by accident.
+//
+// A test might record when asked to, and otherwise replay:
+//
+//	var transport http.RoundTripper
+//	if os.Getenv("RECORD") != "" {
+//	    transport = httperrtest.NewRecorder("testdata/errors.json", http.DefaultTransport)
+//	} else {
+//	    transport, err = httperrtest.LoadReplayer("testdata/errors.json")
+//	}
+//	client := httperr.Client(&http.Client{Transport: transport}, httperr.JSON(APIError{}))
+type Recorder struct {
+	Next http.RoundTripper // the transport that makes real requests. If nil, http.DefaultTransport is used.
+	Path string            // the name of the fixture file
+
+	// RedactHeaders are the request and response headers whose values are
+	// not recorded. If nil, DefaultRedactedHeaders is used.
+	RedactHeaders []string
+
+	// Overwrite allows the Recorder to replace an existing fixture file.
+	Overwrite bool
+
+	mu      sync.Mutex
+	fixture Fixture
+	checked bool // the fixture file has been checked for
+}
+
+// NewRecorder returns a Recorder that writes the fixture file path.
+func NewRecorder(path string, next http.RoundTripper) *Recorder {
+	return &Recorder{Next: next, Path: path}
+}
+
+// RoundTrip implements http.RoundTripper. It returns an error if the
+// fixture cannot be written, or already exists and Overwrite is not set.
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := rec.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if err := rec.checkFixture(); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	bodyHash, req, err := hashRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil || resp.StatusCode < 400 {
+		return resp, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method:     req.Method,
+			Path:       req.URL.Path,
+			Header:     rec.redact(req.Header),
+			BodySHA256: bodyHash,
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     rec.redact(resp.Header),
+			Body:       string(body),
+		},
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.fixture.Interactions = append(rec.fixture.Interactions, interaction)
+	buf, err := json.MarshalIndent(rec.fixture, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(rec.Path, buf, 0644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// checkFixture returns an error if the fixture file exists, unless it may be
+// overwritten or was written by rec.
+func (rec *Recorder) checkFixture() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.checked || rec.Overwrite {
+		return nil
+	}
+	if _, err := os.Stat(rec.Path); err == nil {
+		return fmt.Errorf("httperrtest: fixture %s already exists; set Overwrite to replace it", rec.Path)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	rec.checked = true
+	return nil
+}
+
+// redact returns a copy of header with the values of sensitive headers replaced.
+func (rec *Recorder) redact(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	redactHeaders := rec.RedactHeaders
+	if redactHeaders == nil {
+		redactHeaders = DefaultRedactedHeaders
+	}
+
+	rv := header.Clone()
+	for _, key := range redactHeaders {
+		key = http.CanonicalHeaderKey(key)
+		for i := range rv[key] {
+			rv[key][i] = "[REDACTED]"
+		}
+	}
+	return rv
+}
+
+// Replayer is an http.RoundTripper that responds to requests with the
+// responses in a Fixture, typically recorded by a Recorder. A request
+// matches a recorded request with the same method, path and body. If a
+// request matches several recorded requests, they are used in order, and
+// the last one is repeated.
+type Replayer struct {
+	// Next handles requests that match no recorded request. If nil, such
+	// requests fail with an error, which keeps tests offline.
+	Next http.RoundTripper
+
+	mu      sync.Mutex
+	fixture Fixture
+	used    map[int]bool
+}
+
+// NewReplayer returns a Replayer that serves the responses in fixture.
+func NewReplayer(fixture Fixture) *Replayer {
+	return &Replayer{fixture: fixture, used: map[int]bool{}}
+}
+
+// LoadReplayer returns a Replayer that serves the responses in the fixture
+// file path.
+func LoadReplayer(path string) (*Replayer, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(buf, &fixture); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return NewReplayer(fixture), nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rp *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	bodyHash, req, err := hashRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded, ok := rp.match(req.Method, req.URL.Path, bodyHash)
+	if !ok {
+		if rp.Next == nil {
+			return nil, fmt.Errorf("httperrtest: no recorded response for %s %s", req.Method, req.URL.Path)
+		}
+		return rp.Next.RoundTrip(req)
+	}
+
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader([]byte(recorded.Body))),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// match returns the response to the first unused matching request, or the
+// last matching request if all have been used.
+func (rp *Replayer) match(method, path, bodyHash string) (RecordedResponse, bool) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	last := -1
+	for i, interaction := range rp.fixture.Interactions {
+		r := interaction.Request
+		if r.Method != method || r.Path != path || r.BodySHA256 != bodyHash {
+			continue
+		}
+		if !rp.used[i] {
+			rp.used[i] = true
+			return interaction.Response, true
+		}
+		last = i
+	}
+	if last < 0 {
+		return RecordedResponse{}, false
+	}
+	return rp.fixture.Interactions[last].Response, true
+}
+
+// hashRequestBody returns the hex SHA-256 hash of the body of req, and the
+// request to send in place of req. If the body cannot be read again with
+// GetBody, it is consumed, and the request to send is a copy of req with a
+// new body; req itself is not modified.
+func hashRequestBody(req *http.Request) (string, *http.Request, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if req.GetBody != nil {
+			var rc io.ReadCloser
+			if rc, err = req.GetBody(); err == nil {
+				body, err = ioutil.ReadAll(rc)
+				rc.Close()
+			}
+		} else {
+			body, err = ioutil.ReadAll(req.Body)
+			req.Body.Close()
+			if err == nil {
+				req = req.Clone(req.Context())
+				req.Body = ioutil.NopCloser(bytes.NewReader(body))
+				req.GetBody = func() (io.ReadCloser, error) {
+					return ioutil.NopCloser(bytes.NewReader(body)), nil
+				}
+			}
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), req, nil
+}
+
+var _ http.RoundTripper = &Recorder{}
+var _ http.RoundTripper = &Replayer{}
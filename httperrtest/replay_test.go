@@ -0,0 +1,137 @@
+package httperrtest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crewjam/httperr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.URL.Path == "/ok":
+			w.Write([]byte("ok"))
+			return nil
+		case string(body) == "bob":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cret"})
+			return httperr.Value{StatusCode: http.StatusForbidden, Code: "forbidden"}
+		default:
+			return httperr.NotFound
+		}
+	}))
+	defer server.Close()
+
+	fixturePath := filepath.Join(t.TempDir(), "errors.json")
+
+	// record
+	recorder := NewRecorder(fixturePath, nil)
+	client := httperr.Client(&http.Client{Transport: recorder})
+	do := func(client *http.Client, method, path, body string) error {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer t0ken")
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	assert.NoError(t, do(client, "GET", "/ok", ""))
+	AssertError(t, do(client, "POST", "/users", "alice"), Expect{StatusCode: http.StatusNotFound})
+	AssertError(t, do(client, "POST", "/users", "bob"), Expect{StatusCode: http.StatusForbidden, Code: "forbidden"})
+
+	fixture, err := ioutil.ReadFile(fixturePath)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(fixture), "t0ken")
+	assert.NotContains(t, string(fixture), "s3cret")
+	assert.Contains(t, string(fixture), `"Authorization": [
+            "[REDACTED]"
+          ]`)
+	assert.Equal(t, 2, strings.Count(string(fixture), `"method": "POST"`))
+
+	// replay
+	server.Close()
+	replayer, err := LoadReplayer(fixturePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	client = httperr.Client(&http.Client{Transport: replayer})
+
+	for i := 0; i < 2; i++ {
+		AssertError(t, do(client, "POST", "/users", "bob"), Expect{StatusCode: http.StatusForbidden, Code: "forbidden"})
+		AssertError(t, do(client, "POST", "/users", "alice"), Expect{StatusCode: http.StatusNotFound, Message: "404 Not Found"})
+	}
+
+	err = do(client, "POST", "/users", "carol")
+	assert.EqualError(t, err, `Post "`+server.URL+`/users": httperrtest: no recorded response for POST /users`)
+	err = do(client, "GET", "/ok", "")
+	assert.EqualError(t, err, `Get "`+server.URL+`/ok": httperrtest: no recorded response for GET /ok`)
+}
+
+func TestReplayerSequence(t *testing.T) {
+	replayer := NewReplayer(Fixture{Interactions: []Interaction{
+		{
+			Request:  RecordedRequest{Method: "GET", Path: "/", BodySHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+			Response: RecordedResponse{StatusCode: http.StatusServiceUnavailable},
+		},
+		{
+			Request:  RecordedRequest{Method: "GET", Path: "/", BodySHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+			Response: RecordedResponse{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"1"}}},
+		},
+	}})
+	client := httperr.Client(&http.Client{Transport: replayer})
+
+	for _, statusCode := range []int{503, 429, 429} {
+		_, err := client.Get("http://example.com/")
+		AssertError(t, err, Expect{StatusCode: statusCode})
+	}
+}
+
+func TestRecorderExistingFixture(t *testing.T) {
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})
+	fixturePath := filepath.Join(t.TempDir(), "errors.json")
+	assert.NoError(t, ioutil.WriteFile(fixturePath, []byte(`{"interactions": []}`), 0644))
+
+	// the existing fixture is not replaced
+	client := &http.Client{Transport: NewRecorder(fixturePath, next)}
+	_, err := client.Get("http://example.com/")
+	assert.EqualError(t, err, `Get "http://example.com/": httperrtest: fixture `+fixturePath+` already exists; set Overwrite to replace it`)
+	fixture, _ := ioutil.ReadFile(fixturePath)
+	assert.Equal(t, `{"interactions": []}`, string(fixture))
+
+	// unless Overwrite is set
+	recorder := NewRecorder(fixturePath, next)
+	recorder.Overwrite = true
+	client = &http.Client{Transport: recorder}
+	resp, err := client.Get("http://example.com/")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+	fixture, _ = ioutil.ReadFile(fixturePath)
+	assert.Contains(t, string(fixture), `"status": 404`)
+}
+
+func TestHashRequestBody(t *testing.T) {
+	req, _ := http.NewRequest("POST", "http://example.com/", nil)
+	body := ioutil.NopCloser(strings.NewReader("alice"))
+	req.Body = body
+
+	bodyHash, sent, err := hashRequestBody(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "2bd806c97f0e00af1a1fc3328fa763a9269723c8db8fac4f93af71db186d6e90", bodyHash)
+
+	// req is unchanged, and the request to send has the body
+	assert.Equal(t, body, req.Body)
+	assert.Nil(t, req.GetBody)
+	buf, _ := ioutil.ReadAll(sent.Body)
+	assert.Equal(t, "alice", string(buf))
+}
@@ -0,0 +1,184 @@
+package httperrtest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/crewjam/httperr"
+)
+
+// Route declares how a MockServer responds to requests for a path. Errors
+// are written with httperr.Write, through the Middleware of the server, so
+// they are rendered exactly as a production server would render them.
+// Any error may be used, but an httperr.Value, httperr.Problem or
+// httperr.Response controls the response completely.
+type Route struct {
+	Method string // the method of the request. If empty, any method matches.
+	Path   string // the path of the request
+
+	// Schedule contains the errors for successive calls. A nil entry means
+	// the call succeeds. Once the schedule is exhausted, Err is used.
+	Schedule []error
+
+	// Err is the error for calls after Schedule. If nil, they succeed.
+	Err error
+
+	// Handler serves the calls that succeed. If nil, they get an empty
+	// 200 OK response.
+	Handler http.Handler
+}
+
+// FailFirst returns a schedule in which the first n calls fail with err.
+func FailFirst(n int, err error) []error {
+	schedule := make([]error, n)
+	for i := range schedule {
+		schedule[i] = err
+	}
+	return schedule
+}
+
+// MockServer is an HTTP server that serves canned errors, for testing
+// clients against real server rendering:
+//
+//	server := httperrtest.NewMockServer(t,
+//	    httperrtest.Route{Method: "GET", Path: "/users/alice", Err: UserNotFound.New(nil)},
+//	    httperrtest.Route{Path: "/flaky", Schedule: httperrtest.FailFirst(2, httperr.ServiceUnavailable)},
+//	)
+//	resp, err := client.Get(server.URL + "/users/alice")
+//
+// Requests for paths without a route get 404 Not Found, and requests with
+// the wrong method get 405 Method Not Allowed.
+type MockServer struct {
+	*httptest.Server
+
+	// Middleware handles the requests to the server, and may be changed
+	// before the first request to configure how errors are rendered, for
+	// example by setting a Renderer or enabling error IDs. Its Handler is
+	// ignored.
+	Middleware httperr.Middleware
+
+	mu     sync.Mutex
+	routes []mockRoute
+}
+
+// mockRoute is a Route with functions that return a fresh copy of each of
+// its errors for every call.
+type mockRoute struct {
+	Route
+	schedule []func() error
+	err      func() error
+	calls    int
+}
+
+// NewMockServer starts a MockServer that serves routes. The server is
+// closed when the test finishes.
+func NewMockServer(t testing.TB, routes ...Route) *MockServer {
+	m := &MockServer{}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+	t.Cleanup(m.Close)
+	for _, route := range routes {
+		m.Handle(route)
+	}
+	return m
+}
+
+// Handle adds route to the server. If there is already a route with the same
+// method and path, it is replaced. The body of an httperr.Response error is
+// read here, and each call to the route gets a copy of it.
+func (m *MockServer) Handle(route Route) {
+	mr := mockRoute{Route: route, err: reusableError(route.Err)}
+	for _, err := range route.Schedule {
+		mr.schedule = append(mr.schedule, reusableError(err))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.routes {
+		if m.routes[i].Method == route.Method && m.routes[i].Path == route.Path {
+			m.routes[i] = mr
+			return
+		}
+	}
+	m.routes = append(m.routes, mr)
+}
+
+// reusableError returns a function that returns err, or, if err is an
+// httperr.Response, a copy of it with a fresh reader of its body.
+func reusableError(err error) func() error {
+	re, ok := err.(httperr.Response)
+	if !ok || re.Body == nil {
+		return func() error { return err }
+	}
+	body, _ := ioutil.ReadAll(re.Body)
+	re.Body.Close()
+	return func() error {
+		rv := re
+		rv.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return rv
+	}
+}
+
+// Calls returns the number of requests that have been served by the route
+// for method and path.
+func (m *MockServer) Calls(method, path string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, route := range m.routes {
+		if route.Method == method && route.Path == path {
+			return route.calls
+		}
+	}
+	return 0
+}
+
+func (m *MockServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	mw := m.Middleware
+	mw.Handler = httperr.HandlerFunc(m.serveRoute)
+	mw.ServeHTTP(w, r)
+}
+
+func (m *MockServer) serveRoute(w http.ResponseWriter, r *http.Request) error {
+	route, err := m.call(r)
+	if err != nil {
+		return err
+	}
+	if route.Handler != nil {
+		route.Handler.ServeHTTP(w, r)
+	}
+	return nil
+}
+
+// call finds the route for r and returns it along with the error for this
+// call to it. If there is no route, it returns an error to respond with.
+func (m *MockServer) call(r *http.Request) (Route, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pathMatched := false
+	for i := range m.routes {
+		route := &m.routes[i]
+		if route.Path != r.URL.Path {
+			continue
+		}
+		pathMatched = true
+		if route.Method != "" && route.Method != r.Method {
+			continue
+		}
+
+		n := route.calls
+		route.calls++
+		if n < len(route.schedule) {
+			return route.Route, route.schedule[n]()
+		}
+		return route.Route, route.err()
+	}
+
+	if pathMatched {
+		return Route{}, httperr.MethodNotAllowed
+	}
+	return Route{}, httperr.NotFound
+}
@@ -0,0 +1,126 @@
+package httperrtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/crewjam/httperr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockServer(t *testing.T) {
+	server := NewMockServer(t,
+		Route{
+			Method: "GET",
+			Path:   "/users/alice",
+			Err: httperr.Value{
+				StatusCode: http.StatusNotFound,
+				Err:        fmt.Errorf("no such user"),
+				Public:     true,
+				Code:       "user_not_found",
+			},
+		},
+		Route{
+			Path:     "/flaky",
+			Schedule: FailFirst(2, httperr.ServiceUnavailable),
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "ok")
+			}),
+		},
+		Route{
+			Path: "/problem",
+			Err:  httperr.Problem{Status: http.StatusConflict, Detail: "cannot frob the grob", Code: "grob_frobbed"},
+		},
+	)
+	client := httperr.Client(&http.Client{}, httperr.JSON(httperr.Problem{}))
+
+	get := func(path string) (string, error) {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		req.Header.Set("Accept", "application/problem+json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	_, err := get("/users/alice")
+	AssertError(t, err, Expect{
+		StatusCode: http.StatusNotFound,
+		Code:       "user_not_found",
+		Message:    "no such user",
+	})
+
+	for i := 0; i < 2; i++ {
+		_, err := get("/flaky")
+		AssertError(t, err, Expect{StatusCode: http.StatusServiceUnavailable})
+	}
+	body, err := get("/flaky")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", body)
+	assert.Equal(t, 3, server.Calls("", "/flaky"))
+
+	_, err = get("/problem")
+	AssertError(t, err, Expect{
+		StatusCode: http.StatusConflict,
+		Problem:    &httperr.Problem{Detail: "cannot frob the grob", Code: "grob_frobbed"},
+	})
+
+	_, err = get("/nowhere")
+	AssertError(t, err, Expect{StatusCode: http.StatusNotFound})
+
+	resp, err := client.Post(server.URL+"/users/alice", "text/plain", nil)
+	assert.Nil(t, resp)
+	AssertError(t, err, Expect{StatusCode: http.StatusMethodNotAllowed})
+
+	// routes can be replaced
+	server.Handle(Route{Method: "GET", Path: "/users/alice"})
+	_, err = get("/users/alice")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, server.Calls("GET", "/users/alice"))
+}
+
+func TestMockServerMiddleware(t *testing.T) {
+	server := NewMockServer(t, Route{Path: "/", Err: httperr.InternalServerError})
+	server.Middleware.NewErrorID = func(*http.Request) string { return "abc123" }
+
+	resp, err := http.Get(server.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, "abc123", resp.Header.Get(httperr.ErrorIDHeader))
+	assert.Equal(t, "Internal Server Error (error id: abc123)\n", string(body))
+}
+
+func TestMockServerResponseBody(t *testing.T) {
+	server := NewMockServer(t, Route{
+		Path: "/teapot",
+		Err: httperr.Response{
+			StatusCode: http.StatusTeapot,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       ioutil.NopCloser(strings.NewReader("short and stout")),
+		},
+	})
+
+	// each call gets the whole body
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(server.URL + "/teapot")
+		if !assert.NoError(t, err) {
+			return
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+		assert.Equal(t, "short and stout", string(body))
+	}
+	assert.Equal(t, 2, server.Calls("", "/teapot"))
+}
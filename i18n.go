@@ -0,0 +1,150 @@
+package httperr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Messages maps message keys to localized message templates for a single
+// language. Templates are fmt format strings that are formatted with the
+// MessageArgs of a Value.
+type Messages map[string]string
+
+// DefaultLanguage is the language used when the client does not accept
+// any language for which messages are registered.
+var DefaultLanguage = "en"
+
+var messageRegistry = struct {
+	sync.RWMutex
+	byLanguage map[string]Messages
+}{
+	byLanguage: map[string]Messages{},
+}
+
+// RegisterMessages adds messages for the specified language, which is a
+// BCP 47 language tag such as "en" or "pt-BR". Messages already registered
+// for the language with the same keys are replaced.
+func RegisterMessages(language string, messages Messages) {
+	language = strings.ToLower(language)
+
+	messageRegistry.Lock()
+	defer messageRegistry.Unlock()
+	existing := messageRegistry.byLanguage[language]
+	if existing == nil {
+		existing = Messages{}
+		messageRegistry.byLanguage[language] = existing
+	}
+	for k, v := range messages {
+		existing[k] = v
+	}
+}
+
+// LoadMessages registers the messages in the files of fsys matching pattern,
+// for example "locales/*.json". Each file is a JSON or YAML object mapping
+// message keys to templates, and is named for its language, e.g. "fr.json".
+// fsys may be an embed.FS.
+func LoadMessages(fsys fs.FS, pattern string) error {
+	filenames, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		data, err := fs.ReadFile(fsys, filename)
+		if err != nil {
+			return err
+		}
+
+		messages := Messages{}
+		ext := path.Ext(filename)
+		switch ext {
+		case ".json":
+			err = json.Unmarshal(data, &messages)
+		case ".yaml", ".yml":
+			err = yaml.UnmarshalStrict(data, &messages)
+		default:
+			err = fmt.Errorf("unknown format %q", ext)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: cannot parse messages: %v", filename, err)
+		}
+		RegisterMessages(strings.TrimSuffix(path.Base(filename), ext), messages)
+	}
+	return nil
+}
+
+// localize returns the message for key in the language that best matches
+// the Accept-Language header of r, falling back to DefaultLanguage. The
+// last return value is false if no message is registered for key.
+func localize(r *http.Request, key string, args []interface{}) (string, string, bool) {
+	if key == "" {
+		return "", "", false
+	}
+
+	var acceptLanguage string
+	if r != nil {
+		acceptLanguage = r.Header.Get("Accept-Language")
+	}
+
+	messageRegistry.RLock()
+	defer messageRegistry.RUnlock()
+
+	for _, language := range append(parseAcceptLanguage(acceptLanguage), strings.ToLower(DefaultLanguage)) {
+		for {
+			if tmpl, ok := messageRegistry.byLanguage[language][key]; ok {
+				return language, fmt.Sprintf(tmpl, args...), true
+			}
+			// try the less specific tag, e.g. "fr" for "fr-ca"
+			i := strings.LastIndexByte(language, '-')
+			if i < 0 {
+				break
+			}
+			language = language[:i]
+		}
+	}
+	return "", "", false
+}
+
+// parseAcceptLanguage returns the language tags of an Accept-Language header
+// in lower case, in order of preference.
+func parseAcceptLanguage(header string) []string {
+	type languageQ struct {
+		language string
+		q        float64
+	}
+	var languages []languageQ
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		language := strings.ToLower(strings.TrimSpace(fields[0]))
+		if language == "" || language == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			languages = append(languages, languageQ{language: language, q: q})
+		}
+	}
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].q > languages[j].q })
+
+	rv := make([]string, len(languages))
+	for i, l := range languages {
+		rv[i] = l.language
+	}
+	return rv
+}
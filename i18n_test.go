@@ -0,0 +1,73 @@
+package httperr
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalizedMessages(t *testing.T) {
+	if !assert.NoError(t, LoadMessages(os.DirFS("testdata"), "locales/*")) {
+		return
+	}
+
+	testCases := []struct {
+		Name           string
+		AcceptLanguage string
+		Key            string
+		Args           []interface{}
+		Language       string
+		Body           string
+	}{
+		{"none", "", "user_not_found", []interface{}{"alice"}, "en", "There is no user named alice.\n"},
+		{"exact", "fr", "user_not_found", []interface{}{"alice"}, "fr", "Aucun utilisateur ne s'appelle alice.\n"},
+		{"region", "de, fr-CA;q=0.8, en;q=0.5", "user_not_found", []interface{}{"alice"}, "fr", "Aucun utilisateur ne s'appelle alice.\n"},
+		{"fallback", "de", "user_not_found", []interface{}{"alice"}, "en", "There is no user named alice.\n"},
+		{"missing translation", "fr", "quota_exceeded", []interface{}{100}, "en", "You have exceeded your quota of 100 requests.\n"},
+		{"unknown key", "fr", "bogus", nil, "", "Not Found\n"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			h := HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+				return Value{
+					StatusCode:  http.StatusNotFound,
+					Err:         errors.New("select from users: no rows"),
+					MessageKey:  testCase.Key,
+					MessageArgs: testCase.Args,
+				}
+			})
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("GET", "/", nil)
+			if testCase.AcceptLanguage != "" {
+				r.Header.Set("Accept-Language", testCase.AcceptLanguage)
+			}
+			h.ServeHTTP(w, r)
+
+			assert.Equal(t, 404, w.Code)
+			assert.Equal(t, testCase.Language, w.Header().Get("Content-Language"))
+			assert.Equal(t, testCase.Body, string(w.Body.Bytes()))
+		})
+	}
+
+	t.Run("problem", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", "application/problem+json")
+		r.Header.Set("Accept-Language", "fr")
+		Write(w, r, Value{StatusCode: 404, MessageKey: "user_not_found", MessageArgs: []interface{}{"bob"}})
+
+		assert.Equal(t, "fr", w.Header().Get("Content-Language"))
+		assert.JSONEq(t, `{"title": "Not Found", "status": 404, "detail": "Aucun utilisateur ne s'appelle bob."}`,
+			string(w.Body.Bytes()))
+	})
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	assert.Equal(t, []string{"fr-ca", "en", "de"}, parseAcceptLanguage("de;q=0.1, fr-CA, *;q=0.5, en;q=0.9, es;q=0"))
+	assert.Equal(t, []string{}, parseAcceptLanguage(""))
+}
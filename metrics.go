@@ -0,0 +1,259 @@
+package httperr
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the buckets of
+// the latency histogram used when Metrics.Buckets is not specified.
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics collects statistics about the errors handled by Middleware. It
+// counts errors by status code, application error code, route and whether
+// the error is public, and records the latency of failed requests. The
+// zero value is ready to use.
+//
+// Metrics implements expvar.Var, so it can be published with expvar.Publish,
+// and is an http.Handler that serves the metrics in the Prometheus text
+// exposition format:
+//
+//	metrics := &httperr.Metrics{}
+//	expvar.Publish("httperr", metrics)
+//	http.Handle("/metrics", metrics)
+//	http.Handle("/", httperr.Middleware{Metrics: metrics, Handler: handler})
+type Metrics struct {
+	// Route returns the route label of r, such as "/users/{id}". The number
+	// of distinct routes should be small. If Route is nil, the route label
+	// is empty.
+	Route func(r *http.Request) string
+
+	// Buckets are the upper bounds, in seconds, of the buckets of the latency
+	// histogram. If nil, DefaultLatencyBuckets is used.
+	Buckets []float64
+
+	mu      sync.Mutex
+	errors  map[errorMetricKey]uint64
+	latency map[latencyMetricKey]*histogram
+}
+
+type errorMetricKey struct {
+	StatusCode int
+	Code       string
+	Route      string
+	Public     bool
+}
+
+type latencyMetricKey struct {
+	StatusCode int
+	Route      string
+}
+
+type histogram struct {
+	counts []uint64 // the number of observations <= the corresponding bucket
+	count  uint64
+	sum    float64
+}
+
+// Observe records a failed request r that took duration and failed with err.
+// It is called by Middleware, but may also be used directly.
+func (m *Metrics) Observe(r *http.Request, err error, duration time.Duration) {
+	route := ""
+	if m.Route != nil {
+		route = m.Route(r)
+	}
+	statusCode, _ := ErrorStatus(err)
+	code, _ := ErrorCode(err)
+	buckets := m.buckets()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.errors == nil {
+		m.errors = map[errorMetricKey]uint64{}
+		m.latency = map[latencyMetricKey]*histogram{}
+	}
+
+	m.errors[errorMetricKey{StatusCode: statusCode, Code: code, Route: route, Public: isPublic(err)}]++
+
+	key := latencyMetricKey{StatusCode: statusCode, Route: route}
+	h := m.latency[key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(buckets))}
+		m.latency[key] = h
+	}
+	seconds := duration.Seconds()
+	for i, upperBound := range buckets {
+		if seconds <= upperBound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+func (m *Metrics) buckets() []float64 {
+	if m.Buckets != nil {
+		return m.Buckets
+	}
+	return DefaultLatencyBuckets
+}
+
+// isPublic returns true if err reveals its underlying error to the client.
+func isPublic(err error) bool {
+	err = pkgerrors.Cause(err)
+
+	var p interface{ public() bool }
+	if errors.As(err, &p) {
+		return p.public()
+	}
+	return false
+}
+
+func (e Value) public() bool {
+	return e.Public
+}
+
+// sortedErrorKeys returns the keys of m.errors in a stable order. The caller
+// must hold m.mu.
+func (m *Metrics) sortedErrorKeys() []errorMetricKey {
+	keys := make([]errorMetricKey, 0, len(m.errors))
+	for k := range m.errors {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		if a.StatusCode != b.StatusCode {
+			return a.StatusCode < b.StatusCode
+		}
+		if a.Code != b.Code {
+			return a.Code < b.Code
+		}
+		return !a.Public && b.Public
+	})
+	return keys
+}
+
+// sortedLatencyKeys returns the keys of m.latency in a stable order. The
+// caller must hold m.mu.
+func (m *Metrics) sortedLatencyKeys() []latencyMetricKey {
+	keys := make([]latencyMetricKey, 0, len(m.latency))
+	for k := range m.latency {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Route != keys[j].Route {
+			return keys[i].Route < keys[j].Route
+		}
+		return keys[i].StatusCode < keys[j].StatusCode
+	})
+	return keys
+}
+
+// String returns the metrics as JSON. It implements expvar.Var.
+func (m *Metrics) String() string {
+	type errorCount struct {
+		Status int    `json:"status"`
+		Code   string `json:"code,omitempty"`
+		Route  string `json:"route"`
+		Public bool   `json:"public"`
+		Count  uint64 `json:"count"`
+	}
+	type latency struct {
+		Status  int               `json:"status"`
+		Route   string            `json:"route"`
+		Count   uint64            `json:"count"`
+		Sum     float64           `json:"sum"`
+		Buckets map[string]uint64 `json:"buckets"`
+	}
+	doc := struct {
+		Errors  []errorCount `json:"errors"`
+		Latency []latency    `json:"latency"`
+	}{
+		Errors:  []errorCount{},
+		Latency: []latency{},
+	}
+
+	buckets := m.buckets()
+
+	m.mu.Lock()
+	for _, k := range m.sortedErrorKeys() {
+		doc.Errors = append(doc.Errors, errorCount{
+			Status: k.StatusCode,
+			Code:   k.Code,
+			Route:  k.Route,
+			Public: k.Public,
+			Count:  m.errors[k],
+		})
+	}
+	for _, k := range m.sortedLatencyKeys() {
+		h := m.latency[k]
+		l := latency{Status: k.StatusCode, Route: k.Route, Count: h.count, Sum: h.sum, Buckets: map[string]uint64{}}
+		for i, upperBound := range buckets {
+			l.Buckets[formatFloat(upperBound)] = h.counts[i]
+		}
+		doc.Latency = append(doc.Latency, l)
+	}
+	m.mu.Unlock()
+
+	buf, _ := json.Marshal(doc)
+	return string(buf)
+}
+
+var _ expvar.Var = &Metrics{}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	buckets := m.buckets()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP httperr_errors_total The number of failed requests.")
+	fmt.Fprintln(w, "# TYPE httperr_errors_total counter")
+	for _, k := range m.sortedErrorKeys() {
+		fmt.Fprintf(w, "httperr_errors_total{status=%s,code=%s,route=%s,public=%s} %d\n",
+			promLabel(strconv.Itoa(k.StatusCode)), promLabel(k.Code), promLabel(k.Route),
+			promLabel(strconv.FormatBool(k.Public)), m.errors[k])
+	}
+
+	fmt.Fprintln(w, "# HELP httperr_error_duration_seconds The latency of failed requests.")
+	fmt.Fprintln(w, "# TYPE httperr_error_duration_seconds histogram")
+	for _, k := range m.sortedLatencyKeys() {
+		h := m.latency[k]
+		labels := fmt.Sprintf("status=%s,route=%s", promLabel(strconv.Itoa(k.StatusCode)), promLabel(k.Route))
+		for i, upperBound := range buckets {
+			fmt.Fprintf(w, "httperr_error_duration_seconds_bucket{%s,le=%s} %d\n",
+				labels, promLabel(formatFloat(upperBound)), h.counts[i])
+		}
+		fmt.Fprintf(w, "httperr_error_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(w, "httperr_error_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "httperr_error_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// promLabel returns s as a quoted Prometheus label value.
+func promLabel(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
@@ -0,0 +1,92 @@
+package httperr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareMetrics(t *testing.T) {
+	metrics := &Metrics{
+		Route:   func(r *http.Request) string { return strings.SplitN(r.URL.Path, "/", 3)[1] },
+		Buckets: []float64{0.1, 1},
+	}
+	mw := Middleware{
+		Metrics: metrics,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/users/alice":
+				HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+					return testUserNotFound.Public(errors.New("no such user"))
+				}).ServeHTTP(w, r)
+			case "/users/bob":
+				HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+					return fmt.Errorf("database is on fire")
+				}).ServeHTTP(w, r)
+			case "/teapot":
+				w.Header().Set("X-Foo", "bar")
+				w.WriteHeader(http.StatusTeapot)
+				fmt.Fprintln(w, "short and stout")
+			default:
+				fmt.Fprintln(w, "ok")
+			}
+		}),
+	}
+
+	for _, path := range []string{"/users/alice", "/users/alice", "/users/bob", "/teapot", "/ok"} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", path, nil)
+		mw.ServeHTTP(w, r)
+
+		if path == "/teapot" {
+			assert.Equal(t, 418, w.Code)
+			assert.Equal(t, http.Header{"X-Foo": []string{"bar"}}, w.Header())
+			assert.Equal(t, "short and stout\n", string(w.Body.Bytes()))
+		}
+	}
+
+	slowReq, _ := http.NewRequest("GET", "/slow/", nil)
+	metrics.Observe(slowReq, BadGateway, 2*time.Second)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/metrics", nil)
+	metrics.ServeHTTP(w, r)
+	body := string(w.Body.Bytes())
+	assert.Contains(t, body, "# TYPE httperr_errors_total counter\n"+
+		`httperr_errors_total{status="502",code="",route="slow",public="false"} 1`+"\n"+
+		`httperr_errors_total{status="418",code="",route="teapot",public="false"} 1`+"\n"+
+		`httperr_errors_total{status="404",code="test_user_not_found",route="users",public="true"} 2`+"\n"+
+		`httperr_errors_total{status="500",code="",route="users",public="false"} 1`+"\n")
+	assert.Contains(t, body, `httperr_error_duration_seconds_bucket{status="502",route="slow",le="0.1"} 0`+"\n"+
+		`httperr_error_duration_seconds_bucket{status="502",route="slow",le="1"} 0`+"\n"+
+		`httperr_error_duration_seconds_bucket{status="502",route="slow",le="+Inf"} 1`+"\n"+
+		`httperr_error_duration_seconds_sum{status="502",route="slow"} 2`+"\n"+
+		`httperr_error_duration_seconds_count{status="502",route="slow"} 1`+"\n")
+	assert.Contains(t, body, `httperr_error_duration_seconds_count{status="404",route="users"} 2`+"\n")
+
+	var doc struct {
+		Errors []struct {
+			Status int
+			Code   string
+			Route  string
+			Public bool
+			Count  int
+		}
+	}
+	assert.NoError(t, json.Unmarshal([]byte(metrics.String()), &doc))
+	assert.Len(t, doc.Errors, 4)
+	assert.Equal(t, "test_user_not_found", doc.Errors[2].Code)
+	assert.Equal(t, 2, doc.Errors[2].Count)
+	assert.True(t, doc.Errors[2].Public)
+}
+
+func TestPromLabel(t *testing.T) {
+	assert.Equal(t, `"a\\b\"c\nd"`, promLabel("a\\b\"c\nd"))
+}
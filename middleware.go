@@ -3,16 +3,29 @@ package httperr
 import (
 	"context"
 	"net/http"
+	"time"
 )
 
 type onErrorIndexType int
 
-const onErrorIndex onErrorIndexType = iota
+const (
+	onErrorIndex onErrorIndexType = iota
+	requestStateIndex
+)
+
+// requestState holds information about a request handled by Middleware
+type requestState struct {
+	errorID  string
+	err      error    // the first error reported for the request
+	renderer Renderer // the Renderer of the Middleware or Policy, if any
+	policy   *Policy  // the Policy that applies to the request, if any
+}
 
 // Middleware wraps the provided handler with middleware that captures errors which
 // are returned from HandlerFunc, or reported via ReportError, and invokes the provided
 // callback to render them. If the handler returns a status code >= 400, the response is
-// captured and passed to OnError as a Response.
+// captured and passed to OnError as a Response. Without OnError, such responses are sent
+// as they are written, and only their status code is recorded.
 //
 type Middleware struct {
 	// OnError is a function that is called then a request fails with an error. If this function
@@ -22,35 +35,124 @@ type Middleware struct {
 
 	// Handler is the next handler
 	Handler http.Handler
+
+	// NewErrorID, if not nil, enables error correlation IDs. When a request fails
+	// it is assigned the ID in its X-Request-ID header, or if there isn't one, the
+	// ID returned by NewErrorID. The ID is sent to the client in the X-Error-ID
+	// header and in the body of the error, and the error passed to OnError is
+	// an ErrorWithID, so that the ID can be logged. Use RandomErrorID to
+	// generate random IDs.
+	NewErrorID func(r *http.Request) string
+
+	// Metrics, if not nil, collects statistics about failed requests.
+	Metrics *Metrics
+
+	// Tracer, if not nil, is notified of failed requests so that it can
+	// annotate the active trace span.
+	Tracer Tracer
+
+	// Renderer, if not nil, renders the errors written by Write while handling
+	// the request, in place of DefaultRenderer.
+	Renderer Renderer
+
+	// Policies, if not empty, control how the errors of matching requests are
+	// rendered, redacted and logged. The first Policy that applies to a
+	// request is used.
+	Policies []Policy
+
+	// Log, if not nil, is called for each failed request, after the error
+	// has been written.
+	Log func(r *http.Request, entry LogEntry)
 }
 
 func (m Middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	state := &requestState{renderer: m.Renderer, policy: m.policyFor(r)}
+	if state.policy != nil && state.policy.Renderer != nil {
+		state.renderer = state.policy.Renderer
+	}
+	var didCallOnError bool
+
 	var unwrappedWriter = w
 	var wrappedWriter *basicWriter
 	if m.OnError != nil {
-		wrappedWriter, w = wrapWriter(w)
+		wrappedWriter, w = wrapWriter(w, nil)
+	} else if m.Metrics != nil || m.Tracer != nil || m.Log != nil {
+		// the response is only observed, so send it unchanged as it is written
+		wrappedWriter, w = wrapWriter(w, func(statusCode int) {
+			if !didCallOnError {
+				m.recordError(unwrappedWriter, r, state, Response{
+					StatusCode: statusCode,
+					Header:     unwrappedWriter.Header(),
+				})
+			}
+		})
 	}
 
-	var didCallOnError bool
-	r = r.WithContext(context.WithValue(r.Context(), onErrorIndex, func(err error) {
-		if m.OnError != nil {
-			didCallOnError = true
-			handlerErr := m.OnError(unwrappedWriter, r, err)
-			if handlerErr != nil {
-				Write(unwrappedWriter, r, handlerErr)
-			}
-		}
+	ctx := context.WithValue(r.Context(), requestStateIndex, state)
+	r = r.WithContext(context.WithValue(ctx, onErrorIndex, func(err error) {
+		didCallOnError = true
+		m.handleError(unwrappedWriter, r, state, err)
 	}))
 
 	m.Handler.ServeHTTP(w, r)
 
-	if wrappedWriter != nil && wrappedWriter.statusCode >= 400 && !didCallOnError {
-		err := Response(*wrappedWriter.copy)
-		handlerErr := m.OnError(unwrappedWriter, r, err)
-		if handlerErr != nil {
-			Write(unwrappedWriter, r, handlerErr)
+	if wrappedWriter != nil && wrappedWriter.copy != nil && !didCallOnError {
+		m.handleError(unwrappedWriter, r, state, Response(*wrappedWriter.copy))
+	}
+
+	if m.Metrics != nil && state.err != nil {
+		m.Metrics.Observe(r, state.err, time.Since(start))
+	}
+	if m.Tracer != nil && state.err != nil {
+		statusCode, _ := ErrorStatus(state.err)
+		m.Tracer.RecordError(r.Context(), newTraceEvent(state.err, statusCode, false))
+	}
+	if m.Log != nil && state.err != nil {
+		if entry := newLogEntry(r, state.policy, state.err); entry.Level != LogNone {
+			m.Log(r, entry)
+		}
+	}
+}
+
+// policyFor returns the first of m.Policies that applies to r, or nil.
+func (m Middleware) policyFor(r *http.Request) *Policy {
+	for i := range m.Policies {
+		if m.Policies[i].matches(r) {
+			return &m.Policies[i]
+		}
+	}
+	return nil
+}
+
+func (m Middleware) handleError(w http.ResponseWriter, r *http.Request, state *requestState, err error) {
+	err = m.recordError(w, r, state, err)
+	if m.OnError == nil {
+		Write(w, r, err)
+		return
+	}
+	if handlerErr := m.OnError(w, r, err); handlerErr != nil {
+		Write(w, r, handlerErr)
+	}
+}
+
+// recordError records err as the error of the request and assigns it an
+// error ID if they are enabled. It returns the error to pass to OnError.
+func (m Middleware) recordError(w http.ResponseWriter, r *http.Request, state *requestState, err error) error {
+	if state.err == nil {
+		state.err = err
+	}
+
+	if m.NewErrorID != nil {
+		state.errorID = r.Header.Get(RequestIDHeader)
+		if !validErrorID(state.errorID) {
+			state.errorID = m.NewErrorID(r)
 		}
+		w.Header().Set(ErrorIDHeader, state.errorID)
+		err = ErrorWithID{Err: err, ID: state.errorID}
 	}
+	return err
 }
 
 // ReportError reports the error to the function given in
@@ -1,12 +1,14 @@
 package httperr
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	pkgerrors "github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -114,3 +116,141 @@ func TestMiddlewareSuccess(t *testing.T) {
 	assert.Equal(t, http.Header{"X-Foo": []string{"bar"}}, w.Header())
 	assert.Equal(t, "response body\n", string(w.Body.Bytes()))
 }
+
+func TestMiddlewareWritesErrorsWithoutOnError(t *testing.T) {
+	mw := Middleware{
+		Handler: HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			return Public(http.StatusConflict, fmt.Errorf("cannot frob the grob"))
+		}),
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/foo", nil)
+	mw.ServeHTTP(w, r)
+
+	assert.Equal(t, 409, w.Code)
+	assert.Equal(t, "cannot frob the grob\n", string(w.Body.Bytes()))
+}
+
+func TestMiddlewareErrorID(t *testing.T) {
+	var onErrorErr error
+	mw := Middleware{
+		NewErrorID: func(r *http.Request) string { return "abc123" },
+		OnError: func(w http.ResponseWriter, r *http.Request, err error) error {
+			onErrorErr = err
+			assert.Equal(t, w.Header().Get("X-Error-ID"), ErrorID(r))
+			return err
+		},
+		Handler: HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			assert.Equal(t, "", ErrorID(r))
+			return fmt.Errorf("cannot frob the grob")
+		}),
+	}
+
+	t.Run("text", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/foo", nil)
+		mw.ServeHTTP(w, r)
+
+		assert.Equal(t, 500, w.Code)
+		assert.Equal(t, "abc123", w.Header().Get("X-Error-ID"))
+		assert.Equal(t, "Internal Server Error (error id: abc123)\n", string(w.Body.Bytes()))
+		assert.EqualError(t, onErrorErr, "cannot frob the grob (error id: abc123)")
+
+		var errWithID ErrorWithID
+		assert.True(t, errors.As(onErrorErr, &errWithID))
+		assert.Equal(t, "abc123", errWithID.ID)
+	})
+
+	t.Run("problem", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/foo", nil)
+		r.Header.Set("Accept", "application/problem+json")
+		r.Header.Set("X-Request-ID", "req-42")
+		mw.ServeHTTP(w, r)
+
+		assert.Equal(t, 500, w.Code)
+		assert.Equal(t, "req-42", w.Header().Get("X-Error-ID"))
+		assert.JSONEq(t, `{"title": "Internal Server Error", "status": 500, "error_id": "req-42"}`,
+			string(w.Body.Bytes()))
+	})
+
+	t.Run("invalid request id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/foo", nil)
+		r.Header.Set("X-Request-ID", "<script>")
+		mw.ServeHTTP(w, r)
+		assert.Equal(t, "abc123", w.Header().Get("X-Error-ID"))
+	})
+
+	t.Run("status code", func(t *testing.T) {
+		mw := Middleware{
+			NewErrorID: RandomErrorID,
+			OnError: func(w http.ResponseWriter, r *http.Request, err error) error {
+				var respErr Response
+				assert.True(t, errors.As(err, &respErr))
+				return Value{StatusCode: respErr.StatusCode}
+			},
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			}),
+		}
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/foo", nil)
+		mw.ServeHTTP(w, r)
+
+		assert.Equal(t, 404, w.Code)
+		assert.Len(t, w.Header().Get("X-Error-ID"), 32)
+		assert.Equal(t, "Not Found (error id: "+w.Header().Get("X-Error-ID")+")\n", string(w.Body.Bytes()))
+	})
+}
+
+func TestMiddlewareErrorIDWrappedError(t *testing.T) {
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return pkgerrors.Wrap(NotFound, "lookup")
+	})
+	for _, mw := range []Middleware{
+		{Handler: handler},
+		{Handler: handler, NewErrorID: RandomErrorID},
+		{Handler: handler, NewErrorID: RandomErrorID, OnError: func(w http.ResponseWriter, r *http.Request, err error) error {
+			return err
+		}},
+	} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/foo", nil)
+		mw.ServeHTTP(w, r)
+		assert.Equal(t, 404, w.Code)
+		if mw.NewErrorID != nil {
+			assert.Equal(t, "Not Found (error id: "+w.Header().Get("X-Error-ID")+")\n", w.Body.String())
+		}
+	}
+}
+
+func TestErrorWithIDWithoutErr(t *testing.T) {
+	assert.EqualError(t, ErrorWithID{ID: "abc"}, "error id: abc")
+	assert.Equal(t, "error id: ", fmt.Sprint(ErrorWithID{}))
+}
+
+func TestMiddlewareObservesErrorResponsesWithoutBuffering(t *testing.T) {
+	metrics := &Metrics{}
+	var logged LogEntry
+	mw := Middleware{
+		Metrics: metrics,
+		Log:     func(r *http.Request, entry LogEntry) { logged = entry },
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, "try later")
+			w.(http.Flusher).Flush()
+		}),
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/foo", nil)
+	mw.ServeHTTP(w, r)
+
+	assert.Equal(t, 503, w.Code)
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "try later", w.Body.String())
+	assert.Equal(t, 503, logged.StatusCode)
+	assert.Contains(t, metrics.String(), `"status":503`)
+}
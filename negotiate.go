@@ -0,0 +1,70 @@
+package httperr
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// negotiateContentType returns the member of offers that best matches the
+// Accept header of r, or the first offer if r has no Accept header. If the
+// client accepts none of the offers, the first offer is returned anyway,
+// since an error response in an unacceptable format is better than none.
+func negotiateContentType(r *http.Request, offers ...string) string {
+	accept := ""
+	if r != nil {
+		accept = r.Header.Get("Accept")
+	}
+	if accept == "" {
+		return offers[0]
+	}
+
+	best, bestQ, bestSpecificity := offers[0], 0.0, -1
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, q := parseMediaRange(mediaRange)
+		if q <= 0 {
+			continue
+		}
+		for _, offer := range offers {
+			specificity := matchMediaRange(mediaType, offer)
+			if specificity < 0 {
+				continue
+			}
+			if q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+				best, bestQ, bestSpecificity = offer, q, specificity
+			}
+		}
+	}
+	return best
+}
+
+// parseMediaRange returns the media type and quality value of a single
+// element of an Accept header.
+func parseMediaRange(s string) (string, float64) {
+	parts := strings.Split(s, ";")
+	mediaType := strings.ToLower(strings.TrimSpace(parts[0]))
+	q := 1.0
+	for _, param := range parts[1:] {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "q=") {
+			if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				q = v
+			}
+		}
+	}
+	return mediaType, q
+}
+
+// matchMediaRange returns -1 if mediaRange does not match contentType, otherwise
+// the specificity of the match: 0 for */*, 1 for type/* and 2 for an exact match.
+func matchMediaRange(mediaRange, contentType string) int {
+	switch {
+	case mediaRange == contentType:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(contentType, mediaRange[:len(mediaRange)-1]):
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,70 @@
+// Package otelhttperr provides an httperr.Tracer that records failed requests
+// on OpenTelemetry spans.
+//
+// It is a separate module so that the httperr package itself does not
+// depend on OpenTelemetry.
+//
+//	handler := httperr.Middleware{
+//	    Tracer:  otelhttperr.Tracer{},
+//	    Handler: h,
+//	}
+//	http.Handle("/", otelhttp.NewHandler(handler, "server"))
+package otelhttperr
+
+import (
+	"context"
+
+	"github.com/crewjam/httperr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys recorded on spans in addition to the OpenTelemetry
+// semantic convention attributes.
+const (
+	CodeKey   = attribute.Key("httperr.code")
+	PublicKey = attribute.Key("httperr.public")
+)
+
+// Tracer is an httperr.Tracer that records errors on the span in the context
+// of the request, following the OpenTelemetry semantic conventions for HTTP:
+// the span gets the http.response.status_code and error.type attributes and,
+// for client errors and server errors with a 5xx status, an error status and
+// an exception event.
+type Tracer struct{}
+
+// RecordError implements httperr.Tracer.
+func (Tracer) RecordError(ctx context.Context, event httperr.TraceEvent) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("error.type", event.ErrorType),
+		PublicKey.Bool(event.Public),
+	}
+	if event.StatusCode != 0 {
+		attrs = append(attrs, attribute.Int("http.response.status_code", event.StatusCode))
+	}
+	if event.Code != "" {
+		attrs = append(attrs, CodeKey.String(event.Code))
+	}
+	span.SetAttributes(attrs...)
+
+	// Server spans only report 5xx responses as errors, since 4xx responses
+	// are the client's fault.
+	if !event.Client && event.StatusCode != 0 && event.StatusCode < 500 {
+		return
+	}
+
+	message := httperr.RedactError(event.Err)
+	span.AddEvent("exception", trace.WithAttributes(
+		attribute.String("exception.type", event.ErrorType),
+		attribute.String("exception.message", message),
+	))
+	span.SetStatus(codes.Error, message)
+}
+
+var _ httperr.Tracer = Tracer{}
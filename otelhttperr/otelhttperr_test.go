@@ -0,0 +1,88 @@
+package otelhttperr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/crewjam/httperr"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func recordError(ev httperr.TraceEvent) tracetest.SpanStub {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx, span := provider.Tracer("test").Start(context.Background(), "request")
+	Tracer{}.RecordError(ctx, ev)
+	span.End()
+	return exporter.GetSpans()[0]
+}
+
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	rv := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		rv[kv.Key] = kv.Value
+	}
+	return rv
+}
+
+func TestServerError(t *testing.T) {
+	span := recordError(httperr.TraceEvent{
+		Err:        fmt.Errorf("cannot connect to postgres://admin:hunter2@db/app"),
+		StatusCode: http.StatusInternalServerError,
+		ErrorType:  "500",
+	})
+
+	attrs := attributes(span)
+	assert.Equal(t, int64(500), attrs["http.response.status_code"].AsInt64())
+	assert.Equal(t, "500", attrs["error.type"].AsString())
+	assert.Equal(t, false, attrs[PublicKey].AsBool())
+	assert.Equal(t, codes.Error, span.Status.Code)
+	assert.Equal(t, "cannot connect to postgres://admin:[REDACTED]@db/app", span.Status.Description)
+	if assert.Len(t, span.Events, 1) {
+		assert.Equal(t, "exception", span.Events[0].Name)
+	}
+}
+
+func TestServerClientError(t *testing.T) {
+	span := recordError(httperr.TraceEvent{
+		Err:        httperr.NotFound,
+		StatusCode: http.StatusNotFound,
+		ErrorType:  "user_not_found",
+		Code:       "user_not_found",
+		Public:     true,
+	})
+
+	attrs := attributes(span)
+	assert.Equal(t, int64(404), attrs["http.response.status_code"].AsInt64())
+	assert.Equal(t, "user_not_found", attrs[CodeKey].AsString())
+	assert.Equal(t, true, attrs[PublicKey].AsBool())
+	assert.Equal(t, codes.Unset, span.Status.Code)
+	assert.Len(t, span.Events, 0)
+}
+
+func TestTransportError(t *testing.T) {
+	span := recordError(httperr.TraceEvent{
+		Err:       fmt.Errorf("connection refused"),
+		ErrorType: "*errors.errorString",
+		Client:    true,
+	})
+
+	attrs := attributes(span)
+	_, hasStatus := attrs["http.response.status_code"]
+	assert.False(t, hasStatus)
+	assert.Equal(t, codes.Error, span.Status.Code)
+	assert.Equal(t, "connection refused", span.Status.Description)
+}
+
+func TestNotRecording(t *testing.T) {
+	// must not panic when there is no span
+	Tracer{}.RecordError(context.Background(), httperr.TraceEvent{Err: httperr.NotFound})
+	assert.False(t, trace.SpanFromContext(context.Background()).IsRecording())
+}
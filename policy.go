@@ -0,0 +1,130 @@
+package httperr
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Policy controls how Middleware handles the errors of some of its requests,
+// so that a single Middleware can serve, for example, JSON problems under
+// /api/ and HTML pages elsewhere:
+//
+//	httperr.Middleware{
+//	    Policies: []httperr.Policy{
+//	        {PathPrefix: "/api/", Renderer: httperr.ProblemRenderer},
+//	        {Host: "admin.example.com", Renderer: adminPages, LogLevel: httperr.LogWarn},
+//	    },
+//	    Renderer: httperr.ContentNegotiator{HTML: &httperr.HTMLRenderer{}},
+//	    Log:      logError,
+//	    Handler:  handler,
+//	}
+//
+// A Policy applies to a request if it matches all of Host, PathPrefix and
+// Match that are specified. Middleware uses the first Policy that applies.
+type Policy struct {
+	Host       string                     // the host of the request, without the port (optional)
+	PathPrefix string                     // a prefix of the path of the request (optional)
+	Match      func(r *http.Request) bool // an arbitrary test of the request (optional)
+
+	// Renderer, if not nil, renders errors in place of the Renderer of the
+	// Middleware.
+	Renderer Renderer
+
+	// Redactor, if not nil, removes sensitive information from the text of
+	// errors in debug responses and in the entries passed to Middleware.Log.
+	// It is applied in addition to DefaultRedactor.
+	Redactor Redactor
+
+	// LogLevel is the level of the entries passed to Middleware.Log.
+	LogLevel LogLevel
+}
+
+// matches returns true if the policy applies to r.
+func (p *Policy) matches(r *http.Request) bool {
+	if p.Host != "" {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if !strings.EqualFold(host, p.Host) {
+			return false
+		}
+	}
+	if p.PathPrefix != "" && !strings.HasPrefix(r.URL.Path, p.PathPrefix) {
+		return false
+	}
+	if p.Match != nil && !p.Match(r) {
+		return false
+	}
+	return true
+}
+
+// LogLevel is the severity of a LogEntry.
+type LogLevel int
+
+// Log levels
+const (
+	LogDefault LogLevel = iota // LogError for server errors, and LogInfo for others
+	LogDebug
+	LogInfo
+	LogWarn
+	LogError
+	LogNone // errors are not logged
+)
+
+var logLevelNames = map[LogLevel]string{
+	LogDefault: "default",
+	LogDebug:   "debug",
+	LogInfo:    "info",
+	LogWarn:    "warn",
+	LogError:   "error",
+	LogNone:    "none",
+}
+
+func (l LogLevel) String() string {
+	return logLevelNames[l]
+}
+
+// LogEntry describes a failed request to Middleware.Log.
+type LogEntry struct {
+	Level      LogLevel
+	StatusCode int
+	ErrorID    string // the correlation ID of the error, if error IDs are enabled
+	Text       string // the text of Err, with sensitive information removed
+	Err        error
+}
+
+// newLogEntry returns a LogEntry for err at the level set by policy, which
+// may be nil.
+func newLogEntry(r *http.Request, policy *Policy, err error) LogEntry {
+	statusCode, _ := ErrorStatus(err)
+	entry := LogEntry{
+		StatusCode: statusCode,
+		ErrorID:    ErrorID(r),
+		Text:       redactRequest(r, RedactError(err)),
+		Err:        err,
+	}
+	if policy != nil {
+		entry.Level = policy.LogLevel
+	}
+	if entry.Level == LogDefault {
+		entry.Level = LogInfo
+		if statusCode >= 500 {
+			entry.Level = LogError
+		}
+	}
+	return entry
+}
+
+// redactRequest applies the Redactor of the Policy for r, if any, to s.
+func redactRequest(r *http.Request, s string) string {
+	if r == nil {
+		return s
+	}
+	state, ok := r.Context().Value(requestStateIndex).(*requestState)
+	if !ok || state.policy == nil || state.policy.Redactor == nil {
+		return s
+	}
+	return state.policy.Redactor.Redact(s)
+}
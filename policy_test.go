@@ -0,0 +1,98 @@
+package httperr
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicies(t *testing.T) {
+	var entries []LogEntry
+	h := Middleware{
+		Policies: []Policy{
+			{PathPrefix: "/api/", Renderer: ProblemRenderer, LogLevel: LogWarn},
+			{Host: "admin.example.com", LogLevel: LogNone},
+			{
+				Match:    func(r *http.Request) bool { return r.URL.Query().Get("html") != "" },
+				Renderer: &HTMLRenderer{},
+			},
+		},
+		Log: func(r *http.Request, entry LogEntry) {
+			entries = append(entries, entry)
+		},
+		Handler: HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+			return NotFound
+		}),
+	}
+
+	serve := func(url string) *httptest.ResponseRecorder {
+		entries = nil
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", url, nil)
+		h.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		return w
+	}
+
+	w := serve("http://www.example.com/api/users")
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, LogWarn, entries[0].Level)
+		assert.Equal(t, http.StatusNotFound, entries[0].StatusCode)
+		assert.Equal(t, "404 Not Found", entries[0].Text)
+	}
+
+	w = serve("http://ADMIN.example.com:8080/api-docs")
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Len(t, entries, 0)
+
+	w = serve("http://www.example.com/app?html=1")
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, LogInfo, entries[0].Level)
+	}
+
+	w = serve("http://www.example.com/app")
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Len(t, entries, 1)
+}
+
+func TestPolicyRedactor(t *testing.T) {
+	var entry LogEntry
+	h := Middleware{
+		Policies: []Policy{{
+			PathPrefix: "/billing/",
+			Redactor: Redaction{Rules: []RedactionRule{{
+				Pattern:     regexp.MustCompile(`\b\d{4}-\d{4}-\d{4}-\d{4}\b`),
+				Replacement: "[REDACTED CARD]",
+			}}},
+		}},
+		Log: func(r *http.Request, e LogEntry) {
+			entry = e
+		},
+		Handler: HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+			return fmt.Errorf("card 4111-1111-1111-1111 declined for bob@example.com")
+		}),
+	}
+
+	Debug = true
+	defer func() { Debug = false }()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/billing/charge", nil)
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, LogError, entry.Level)
+	assert.Equal(t, "card [REDACTED CARD] declined for [REDACTED EMAIL]", entry.Text)
+	assert.Contains(t, string(w.Body.Bytes()), "  card [REDACTED CARD] declined for [REDACTED EMAIL]\n")
+
+	// the policy does not apply to other paths
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/shop/charge", nil)
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "card 4111-1111-1111-1111 declined for [REDACTED EMAIL]", entry.Text)
+}
@@ -0,0 +1,84 @@
+package httperr
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is a problem details document as described in RFC 7807. Errors
+// written by Value are rendered as a Problem when the client accepts
+// application/problem+json or application/json.
+//
+// Problem also implements error, so it can be used with JSON() to decode
+// problem documents returned from an API:
+//
+//	client := httperr.Client(http.DefaultClient, httperr.JSON(httperr.Problem{}))
+type Problem struct {
+	Type        string `json:"type,omitempty"`         // a URI reference that identifies the problem type
+	Title       string `json:"title,omitempty"`        // a short, human-readable summary of the problem type
+	Status      int    `json:"status,omitempty"`       // the HTTP status code
+	Detail      string `json:"detail,omitempty"`       // a human-readable explanation specific to this occurrence
+	Instance    string `json:"instance,omitempty"`     // a URI reference that identifies this occurrence
+	Code        string `json:"code,omitempty"`         // the application error code
+	NumericCode int    `json:"numeric_code,omitempty"` // the numeric form of the application error code
+	ErrorID     string `json:"error_id,omitempty"`     // the correlation ID assigned by Middleware
+
+	Debug *DebugInfo `json:"debug,omitempty"` // private details of the error, included only in debug mode
+
+	text string // the text of a Value with a Title, which TextRenderer shows instead of the title
+}
+
+func (p Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	_, statusText := p.StatusCodeAndText()
+	return statusText
+}
+
+// StatusCodeAndText returns the status code and text of the error
+func (p Problem) StatusCodeAndText() (int, string) {
+	statusCode := p.Status
+	if statusCode == 0 {
+		statusCode = http.StatusInternalServerError
+	}
+	statusText := p.Title
+	if statusText == "" {
+		statusText = http.StatusText(statusCode)
+	}
+	return statusCode, statusText
+}
+
+// ErrorCode returns the application error code of the problem.
+func (p Problem) ErrorCode() (string, int) {
+	return p.Code, p.NumericCode
+}
+
+func (p Problem) public() bool {
+	return true
+}
+
+// Is returns true if target is the Code of the problem.
+func (p Problem) Is(target error) bool {
+	return isCode(p.Code, target)
+}
+
+// WriteError writes the problem to w as application/problem+json.
+func (p Problem) WriteError(w http.ResponseWriter, r *http.Request) {
+	statusCode, _ := p.StatusCodeAndText()
+	if p.Status == 0 {
+		p.Status = statusCode
+	}
+	if p.Code != "" {
+		w.Header().Set(ErrorCodeHeader, p.Code)
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(p)
+}
+
+var _ error = Problem{}
+var _ Writer = Problem{}
+var _ errorCoder = Problem{}
+var _ statusCodeAndTexter = Problem{}
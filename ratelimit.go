@@ -0,0 +1,201 @@
+package httperr
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// RateLimit describes the state of a client's rate limit as conveyed by the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers from the
+// IETF RateLimit header fields draft.
+type RateLimit struct {
+	Limit     int           // the request quota for the current window
+	Remaining int           // the number of requests remaining in the current window
+	Reset     time.Duration // the time remaining until the window resets
+}
+
+// RetryError is an error that tells the client when it may retry the request.
+// It is typically a http.StatusTooManyRequests or http.StatusServiceUnavailable
+// error. When written to a client the Retry-After header is set, as well as the
+// RateLimit headers if RateLimit is specified.
+type RetryError struct {
+	Value
+	Delay     time.Duration // how long the client should wait before retrying
+	At        time.Time     // when the client may retry. Used only if Delay is zero.
+	RateLimit *RateLimit    // the state of the client's rate limit (optional)
+}
+
+// NewTooManyRequests returns a new http.StatusTooManyRequests error wrapping
+// err which instructs the client to retry after the specified delay. limit
+// may be nil.
+func NewTooManyRequests(err error, retryAfter time.Duration, limit *RateLimit) error {
+	return RetryError{
+		Value:     Value{StatusCode: http.StatusTooManyRequests, Err: err},
+		Delay:     retryAfter,
+		RateLimit: limit,
+	}
+}
+
+// NewTooManyRequestsUntil returns a new http.StatusTooManyRequests error
+// wrapping err which instructs the client to retry at the specified time.
+// limit may be nil.
+func NewTooManyRequestsUntil(err error, retryAt time.Time, limit *RateLimit) error {
+	return RetryError{
+		Value:     Value{StatusCode: http.StatusTooManyRequests, Err: err},
+		At:        retryAt,
+		RateLimit: limit,
+	}
+}
+
+// NewServiceUnavailable returns a new http.StatusServiceUnavailable error
+// wrapping err which instructs the client to retry after the specified delay.
+func NewServiceUnavailable(err error, retryAfter time.Duration) error {
+	return RetryError{
+		Value: Value{StatusCode: http.StatusServiceUnavailable, Err: err},
+		Delay: retryAfter,
+	}
+}
+
+// NewServiceUnavailableUntil returns a new http.StatusServiceUnavailable error
+// wrapping err which instructs the client to retry at the specified time.
+func NewServiceUnavailableUntil(err error, retryAt time.Time) error {
+	return RetryError{
+		Value: Value{StatusCode: http.StatusServiceUnavailable, Err: err},
+		At:    retryAt,
+	}
+}
+
+// RetryAfter returns how long the client should wait before retrying.
+func (e RetryError) RetryAfter() time.Duration {
+	if e.Delay != 0 || e.At.IsZero() {
+		return e.Delay
+	}
+	if d := time.Until(e.At); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// WriteError writes an error response to w including the Retry-After and
+// RateLimit headers.
+func (e RetryError) WriteError(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case e.Delay != 0:
+		w.Header().Set("Retry-After", formatSeconds(e.Delay))
+	case !e.At.IsZero():
+		w.Header().Set("Retry-After", e.At.UTC().Format(http.TimeFormat))
+	}
+	if e.RateLimit != nil {
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(e.RateLimit.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(e.RateLimit.Remaining))
+		w.Header().Set("RateLimit-Reset", formatSeconds(e.RateLimit.Reset))
+	}
+	e.Value.WriteError(w, r)
+}
+
+// formatSeconds returns d as a whole number of seconds, rounded up.
+func formatSeconds(d time.Duration) string {
+	seconds := int64(d / time.Second)
+	if d%time.Second > 0 {
+		seconds++
+	}
+	if seconds < 0 {
+		seconds = 0
+	}
+	return strconv.FormatInt(seconds, 10)
+}
+
+var _ error = RetryError{}
+var _ Writer = RetryError{}
+
+type retryAfterer interface {
+	RetryAfter() time.Duration
+}
+
+// RetryAfter returns how long the client should wait before retrying the
+// request that produced err. The second return value is false if err does not
+// specify when to retry.
+func RetryAfter(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+
+	err = pkgerrors.Cause(err)
+
+	var ra retryAfterer
+	if errors.As(err, &ra) {
+		return ra.RetryAfter(), true
+	}
+	return 0, false
+}
+
+// ParseRetryAfter parses the value of a Retry-After header, which is either
+// a number of seconds or an HTTP date.
+func ParseRetryAfter(value string) (delay time.Duration, at time.Time, ok bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, time.Time{}, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, time.Time{}, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return 0, t, true
+	}
+	return 0, time.Time{}, false
+}
+
+// ParseRateLimit parses the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers. It returns nil if RateLimit-Limit is not present
+// or cannot be parsed. Quota policy parameters, e.g. "100;w=60", are ignored.
+func ParseRateLimit(header http.Header) *RateLimit {
+	firstInt := func(name string) (int, bool) {
+		value := header.Get(name)
+		if i := strings.IndexAny(value, ",;"); i >= 0 {
+			value = value[:i]
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		return n, err == nil
+	}
+
+	limit, ok := firstInt("RateLimit-Limit")
+	if !ok {
+		return nil
+	}
+	rv := RateLimit{Limit: limit}
+	rv.Remaining, _ = firstInt("RateLimit-Remaining")
+	reset, _ := firstInt("RateLimit-Reset")
+	rv.Reset = time.Duration(reset) * time.Second
+	return &rv
+}
+
+// RateLimits returns a ClientArg that turns responses carrying a Retry-After
+// or RateLimit-Limit header into a RetryError. The Err of the RetryError is
+// the error produced by the rest of the chain of error handlers, for example
+// a decoded JSON error, or else the original Response.
+//
+// RateLimits must be given to Client before JSON. A JSON stage that decodes
+// the error does not call the stages after it, so a RateLimits stage after
+// it is never called, and the retry information is lost.
+func RateLimits() ClientArg {
+	return HandleErrors(func(next ErrorFunc) ErrorFunc {
+		return func(req *http.Request, resp *http.Response) error {
+			delay, at, hasRetryAfter := ParseRetryAfter(resp.Header.Get("Retry-After"))
+			limit := ParseRateLimit(resp.Header)
+			if !hasRetryAfter && limit == nil {
+				return next(req, resp)
+			}
+			return RetryError{
+				Value:     Value{StatusCode: resp.StatusCode, Err: next(req, resp)},
+				Delay:     delay,
+				At:        at,
+				RateLimit: limit,
+			}
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package httperr
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryErrorWriteError(t *testing.T) {
+	h := HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+		return NewTooManyRequests(errors.New("slow down"), 1500*time.Millisecond,
+			&RateLimit{Limit: 100, Remaining: 0, Reset: 30 * time.Second})
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, 429, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Equal(t, "100", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "Too Many Requests\n", string(w.Body.Bytes()))
+
+	retryAt := time.Date(2020, 2, 18, 12, 0, 0, 0, time.UTC)
+	h = HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+		return NewServiceUnavailableUntil(errors.New("maintenance"), retryAt)
+	})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, 503, w.Code)
+	assert.Equal(t, "Tue, 18 Feb 2020 12:00:00 GMT", w.Header().Get("Retry-After"))
+	assert.Equal(t, "", w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRetryAfter(t *testing.T) {
+	_, ok := RetryAfter(errors.New("nope"))
+	assert.False(t, ok)
+
+	d, ok := RetryAfter(NewServiceUnavailable(nil, time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, d)
+
+	d, ok = RetryAfter(NewTooManyRequestsUntil(nil, time.Now().Add(-time.Minute), nil))
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), d)
+}
+
+func TestClientRateLimits(t *testing.T) {
+	transport := Transport{
+		Next: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			resp := http.Response{}
+			resp.StatusCode = 429
+			resp.Header = http.Header{}
+			resp.Header.Set("Retry-After", "120")
+			resp.Header.Set("RateLimit-Limit", "100, 100;w=60")
+			resp.Header.Set("RateLimit-Remaining", "0")
+			resp.Header.Set("RateLimit-Reset", "120")
+			resp.Body = ioutil.NopCloser(strings.NewReader(`slow down`))
+			return &resp, nil
+		}),
+	}
+	RateLimits()(&transport)
+
+	client := http.Client{Transport: transport}
+	_, err := client.Get("/foo")
+
+	d, ok := RetryAfter(err)
+	assert.True(t, ok)
+	assert.Equal(t, 120*time.Second, d)
+
+	var retryErr RetryError
+	if !assert.True(t, errors.As(err, &retryErr)) {
+		return
+	}
+	assert.Equal(t, &RateLimit{Limit: 100, Remaining: 0, Reset: 120 * time.Second}, retryErr.RateLimit)
+
+	statusCode, _ := StatusCodeAndText(err)
+	assert.Equal(t, 429, statusCode)
+}
@@ -0,0 +1,148 @@
+package httperr
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// Redactor removes sensitive information, such as credentials and personal
+// data, from the text of an error.
+type Redactor interface {
+	Redact(s string) string
+}
+
+// RedactorFunc is an adapter to allow the use of ordinary functions as a Redactor.
+type RedactorFunc func(s string) string
+
+// Redact calls f(s).
+func (f RedactorFunc) Redact(s string) string {
+	return f(s)
+}
+
+// RedactionRule replaces the matches of Pattern with Replacement, which
+// may refer to submatches of Pattern as in regexp.Regexp.Expand.
+type RedactionRule struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+var (
+	// RedactBearerTokens is a RedactionRule that redacts bearer tokens, as
+	// they appear in the Authorization header.
+	RedactBearerTokens = RedactionRule{
+		Name:        "bearer-token",
+		Pattern:     regexp.MustCompile(`(?i)\b(bearer)\s+[A-Za-z0-9\-._~+/]+=*`),
+		Replacement: "$1 [REDACTED]",
+	}
+
+	// RedactURLPasswords is a RedactionRule that redacts passwords in the
+	// userinfo of URLs, such as database connection strings.
+	RedactURLPasswords = RedactionRule{
+		Name:        "url-password",
+		Pattern:     regexp.MustCompile(`\b([a-zA-Z][a-zA-Z0-9+.\-]*://[^:/?#\s@]*:)[^@/?#\s]+@`),
+		Replacement: "${1}[REDACTED]@",
+	}
+
+	// RedactEmailAddresses is a RedactionRule that redacts email addresses.
+	RedactEmailAddresses = RedactionRule{
+		Name:        "email-address",
+		Pattern:     regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		Replacement: "[REDACTED EMAIL]",
+	}
+)
+
+// Redaction is a Redactor that applies Rules in order. Text matched by a
+// rule is left alone if it matches any of the Allow patterns.
+type Redaction struct {
+	Rules []RedactionRule
+	Allow []*regexp.Regexp
+}
+
+// Redact returns s with the matches of each rule replaced.
+func (rd Redaction) Redact(s string) string {
+	for _, rule := range rd.Rules {
+		s = rd.apply(rule, s)
+	}
+	return s
+}
+
+func (rd Redaction) apply(rule RedactionRule, s string) string {
+	var buf []byte
+	last := 0
+	for _, match := range rule.Pattern.FindAllStringSubmatchIndex(s, -1) {
+		if rd.allowed(s[match[0]:match[1]]) {
+			continue
+		}
+		buf = append(buf, s[last:match[0]]...)
+		buf = rule.Pattern.ExpandString(buf, rule.Replacement, s, match)
+		last = match[1]
+	}
+	if buf == nil {
+		return s
+	}
+	return string(append(buf, s[last:]...))
+}
+
+func (rd Redaction) allowed(s string) bool {
+	for _, allow := range rd.Allow {
+		if allow.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultRedactor is applied to the text of errors when they are reported:
+// by RedactError, in the LogEntry of Middleware, and in debug mode. The
+// Error method of errors is not affected. It may be replaced, or set to nil
+// to disable redaction.
+var DefaultRedactor Redactor = Redaction{
+	Rules: []RedactionRule{
+		RedactBearerTokens,
+		RedactURLPasswords,
+		RedactEmailAddresses,
+	},
+}
+
+// redact applies DefaultRedactor to s.
+func redact(s string) string {
+	if DefaultRedactor == nil {
+		return s
+	}
+	return DefaultRedactor.Redact(s)
+}
+
+// RedactError returns the text of err with sensitive information removed
+// by DefaultRedactor. It is intended for logging errors, for example from
+// Middleware.OnError.
+func RedactError(err error) string {
+	if err == nil {
+		return ""
+	}
+	return redact(err.Error())
+}
+
+// Secret is a string that is never revealed when formatted, so that it can
+// be safely included in errors:
+//
+//	return fmt.Errorf("cannot log in with password %v", httperr.Secret(password))
+type Secret string
+
+const redacted = "[REDACTED]"
+
+func (s Secret) String() string { return redacted }
+
+// GoString implements fmt.GoStringer so that %#v does not reveal the secret.
+func (s Secret) GoString() string { return redacted }
+
+// Format implements fmt.Formatter so that no verb reveals the secret.
+func (s Secret) Format(f fmt.State, verb rune) { fmt.Fprint(f, redacted) }
+
+// MarshalJSON implements json.Marshaler so that the secret is not revealed
+// when encoded.
+func (s Secret) MarshalJSON() ([]byte, error) { return json.Marshal(redacted) }
+
+// Reveal returns the value of the secret.
+func (s Secret) Reveal() string { return string(s) }
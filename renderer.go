@@ -0,0 +1,287 @@
+package httperr
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strconv"
+)
+
+// Renderer writes the response for a failed request, described by p. The
+// Renderer chooses the format of the response, but must use p.Status as
+// the status code.
+//
+// Value.WriteError, and therefore Write, renders errors with the Renderer
+// of the Middleware that handles the request, or if there isn't one, with
+// DefaultRenderer.
+type Renderer interface {
+	Render(w http.ResponseWriter, r *http.Request, p Problem)
+}
+
+// RendererFunc is an adapter to allow the use of ordinary functions as a Renderer.
+type RendererFunc func(w http.ResponseWriter, r *http.Request, p Problem)
+
+// Render calls f(w, r, p).
+func (f RendererFunc) Render(w http.ResponseWriter, r *http.Request, p Problem) {
+	f(w, r, p)
+}
+
+var (
+	// TextRenderer is a Renderer that writes the problem as text/plain. The
+	// text is the title of the problem if it is not the standard text of the
+	// status code, or else the detail, if any. The text of a Value with a
+	// Title is the same as if it had none. In debug mode it also writes the
+	// chain of errors and the stack trace.
+	TextRenderer Renderer = RendererFunc(renderText)
+
+	// ProblemRenderer is a Renderer that writes the problem as
+	// application/problem+json.
+	ProblemRenderer Renderer = RendererFunc(func(w http.ResponseWriter, r *http.Request, p Problem) {
+		p.WriteError(w, r)
+	})
+)
+
+func renderText(w http.ResponseWriter, r *http.Request, p Problem) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+
+	if p.Debug == nil {
+		message := p.Title
+		if p.text != "" {
+			message = p.text
+		} else if p.Detail != "" && p.Title == http.StatusText(p.Status) {
+			message = p.Detail
+		}
+		if p.ErrorID != "" {
+			message = fmt.Sprintf("%s (error id: %s)", message, p.ErrorID)
+		}
+		fmt.Fprintln(w, message)
+		return
+	}
+
+	fmt.Fprintf(w, "%d %s\n", p.Status, p.Title)
+	if p.Detail != "" {
+		fmt.Fprintf(w, "%s\n", p.Detail)
+	}
+	if p.ErrorID != "" {
+		fmt.Fprintf(w, "error id: %s\n", p.ErrorID)
+	}
+	fmt.Fprintf(w, "\nerrors:\n")
+	for _, text := range p.Debug.Chain {
+		fmt.Fprintf(w, "  %s\n", text)
+	}
+	if len(p.Debug.Stack) > 0 {
+		fmt.Fprintf(w, "\nstack:\n")
+		for _, frame := range p.Debug.Stack {
+			fmt.Fprintf(w, "  %s\n", frame)
+		}
+	}
+}
+
+// ContentNegotiator is a Renderer that chooses another Renderer according to
+// the Accept header of the request. If the request has no Accept header, or
+// accepts none of the formats, the response is text.
+//
+// The zero value offers text and JSON. To serve HTML error pages to browsers:
+//
+//	httperr.DefaultRenderer = httperr.ContentNegotiator{HTML: &httperr.HTMLRenderer{}}
+type ContentNegotiator struct {
+	Text Renderer // renders text/plain. If nil, TextRenderer is used.
+	JSON Renderer // renders application/problem+json and application/json. If nil, ProblemRenderer is used.
+	HTML Renderer // renders text/html. If nil, HTML is not offered.
+}
+
+// Render renders p with the Renderer for the best content type.
+func (c ContentNegotiator) Render(w http.ResponseWriter, r *http.Request, p Problem) {
+	offers := []string{"text/plain", "application/problem+json", "application/json"}
+	if c.HTML != nil {
+		offers = append(offers, "text/html")
+	}
+
+	switch negotiateContentType(r, offers...) {
+	case "application/problem+json", "application/json":
+		if c.JSON != nil {
+			c.JSON.Render(w, r, p)
+			return
+		}
+		ProblemRenderer.Render(w, r, p)
+	case "text/html":
+		c.HTML.Render(w, r, p)
+	default:
+		if c.Text != nil {
+			c.Text.Render(w, r, p)
+			return
+		}
+		TextRenderer.Render(w, r, p)
+	}
+}
+
+// DefaultRenderer renders errors that are not handled by a Middleware with
+// a Renderer. It may be replaced to change how errors are rendered
+// throughout a program.
+var DefaultRenderer Renderer = ContentNegotiator{}
+
+// rendererFor returns the Renderer to use for the response to r.
+func rendererFor(r *http.Request) Renderer {
+	if r != nil {
+		if state, ok := r.Context().Value(requestStateIndex).(*requestState); ok && state.renderer != nil {
+			return state.renderer
+		}
+	}
+	if DefaultRenderer == nil {
+		return ContentNegotiator{}
+	}
+	return DefaultRenderer
+}
+
+// HTMLPage is the data passed to the templates of an HTMLRenderer.
+type HTMLPage struct {
+	Problem
+	Lang string       // the language of the page, from the Content-Language header of the response
+	CSS  template.CSS // the CSS of the HTMLRenderer
+}
+
+// HTMLRenderer is a Renderer that writes an HTML error page. The text of the
+// problem is escaped by html/template, so public error messages cannot
+// inject markup into the page.
+//
+// To render a problem with status 404, HTMLRenderer executes the first of
+// the templates "404.html", "4xx.html" and "error.html" that is defined in
+// Templates, with an HTMLPage as data. If none of them is defined, or
+// Templates is nil, it uses a built-in page. In debug mode, the built-in
+// page also shows the chain of errors and the stack trace.
+type HTMLRenderer struct {
+	Templates *template.Template
+
+	// CSS is added to the stylesheet of the built-in page. The colors of the
+	// page can be changed by setting the custom properties --httperr-fg,
+	// --httperr-bg, --httperr-accent and --httperr-muted.
+	CSS template.CSS
+}
+
+// LoadHTMLRenderer returns an HTMLRenderer whose templates are parsed from the
+// files in fsys that match patterns, for example an embed.FS:
+//
+//	//go:embed errors/*.html
+//	var errorTemplates embed.FS
+//
+//	renderer, err := httperr.LoadHTMLRenderer(errorTemplates, "errors/*.html")
+//
+// Templates are named by the base names of their files.
+func LoadHTMLRenderer(fsys fs.FS, patterns ...string) (*HTMLRenderer, error) {
+	tmpl, err := template.ParseFS(fsys, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	return &HTMLRenderer{Templates: tmpl}, nil
+}
+
+// template returns the template to use for status.
+func (h *HTMLRenderer) template(status int) *template.Template {
+	if h.Templates != nil {
+		for _, name := range []string{
+			strconv.Itoa(status) + ".html",
+			strconv.Itoa(status/100) + "xx.html",
+			"error.html",
+		} {
+			if t := h.Templates.Lookup(name); t != nil {
+				return t
+			}
+		}
+	}
+	return htmlTemplate
+}
+
+// Render writes p as an HTML page.
+func (h *HTMLRenderer) Render(w http.ResponseWriter, r *http.Request, p Problem) {
+	page := HTMLPage{Problem: p, Lang: w.Header().Get("Content-Language"), CSS: h.CSS}
+	if page.Lang == "" {
+		page.Lang = DefaultLanguage
+	}
+
+	// render to a buffer first, so that a failing template does not produce
+	// a truncated page
+	buf := bytes.Buffer{}
+	if err := h.template(p.Status).Execute(&buf, page); err != nil {
+		buf.Reset()
+		htmlTemplate.Execute(&buf, page)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	w.Write(buf.Bytes())
+}
+
+var _ Renderer = &HTMLRenderer{}
+var _ Renderer = ContentNegotiator{}
+
+var htmlTemplate = template.Must(template.New("error.html").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Status}} {{.Title}}</title>
+<style>
+:root {
+  --httperr-fg: #1f2328;
+  --httperr-bg: #ffffff;
+  --httperr-accent: #b3261e;
+  --httperr-muted: #59636e;
+}
+@media (prefers-color-scheme: dark) {
+  :root {
+    --httperr-fg: #e6edf3;
+    --httperr-bg: #0d1117;
+    --httperr-accent: #ff7b72;
+    --httperr-muted: #9198a1;
+  }
+}
+body {
+  margin: 0;
+  padding: 2rem 1rem;
+  font-family: system-ui, -apple-system, "Segoe UI", sans-serif;
+  line-height: 1.5;
+  color: var(--httperr-fg);
+  background: var(--httperr-bg);
+}
+main { max-width: 40rem; margin: 0 auto; }
+h1 { font-size: 1.75rem; }
+.status { display: block; font-size: 1rem; color: var(--httperr-accent); }
+.error-id { color: var(--httperr-muted); }
+pre { overflow-x: auto; font-size: 0.875rem; }
+{{.CSS}}
+</style>
+</head>
+<body>
+<main>
+<h1><span class="status">Error {{.Status}}</span> {{.Title}}</h1>
+{{- if .Detail}}
+<p>{{.Detail}}</p>
+{{- end}}
+{{- if .ErrorID}}
+<p class="error-id">Error ID: <code>{{.ErrorID}}</code></p>
+{{- end}}
+{{- if .Debug}}
+<h2>Errors</h2>
+<ol>
+{{- range .Debug.Chain}}
+<li><pre>{{.}}</pre></li>
+{{- end}}
+</ol>
+{{- if .Debug.Stack}}
+<h2>Stack trace</h2>
+<pre>
+{{- range .Debug.Stack}}
+{{.}}
+{{- end}}
+</pre>
+{{- end}}
+{{- end}}
+</main>
+</body>
+</html>
+`))
@@ -0,0 +1,113 @@
+package httperr
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentNegotiator(t *testing.T) {
+	renderer := ContentNegotiator{HTML: &HTMLRenderer{}}
+	p := Value{StatusCode: http.StatusConflict, Err: fmt.Errorf("cannot frob the grob"), Public: true}.Problem()
+
+	for accept, contentType := range map[string]string{
+		"":                                 "text/plain; charset=utf-8",
+		"image/png":                        "text/plain; charset=utf-8",
+		"application/json":                 "application/problem+json",
+		"text/html,application/xml;q=0.9":  "text/html; charset=utf-8",
+		"text/html;q=0.5,application/json": "application/problem+json",
+	} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", accept)
+		renderer.Render(w, r, p)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, contentType, w.Header().Get("Content-Type"), accept)
+	}
+
+	// without an HTML renderer, browsers get text
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "text/html,*/*;q=0.8")
+	ContentNegotiator{}.Render(w, r, p)
+	assert.Equal(t, "cannot frob the grob\n", string(w.Body.Bytes()))
+}
+
+func TestHTMLRenderer(t *testing.T) {
+	h := HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+		return Public(http.StatusBadRequest, fmt.Errorf(`bad name "<script>alert(1)</script>"`))
+	})
+
+	oldRenderer := DefaultRenderer
+	DefaultRenderer = ContentNegotiator{HTML: &HTMLRenderer{CSS: ":root { --httperr-accent: purple; }"}}
+	defer func() { DefaultRenderer = oldRenderer }()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "text/html")
+	h.ServeHTTP(w, r)
+
+	body := string(w.Body.Bytes())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Contains(t, body, `<html lang="en">`)
+	assert.Contains(t, body, `<h1><span class="status">Error 400</span> Bad Request</h1>`)
+	assert.Contains(t, body, "<p>bad name &#34;&lt;script&gt;alert(1)&lt;/script&gt;&#34;</p>")
+	assert.NotContains(t, body, "<script>")
+	assert.Contains(t, body, ":root { --httperr-accent: purple; }")
+	assert.NotContains(t, body, "<h2>Errors</h2>")
+}
+
+func TestHTMLRendererTemplates(t *testing.T) {
+	renderer, err := LoadHTMLRenderer(os.DirFS("testdata/templates"), "*.html")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	render := func(p Problem) string {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		renderer.Render(w, r, p)
+		assert.Equal(t, p.Status, w.Code)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+		return string(w.Body.Bytes())
+	}
+
+	assert.Equal(t, "<p class=\"not-found\">Nothing to see at /a&lt;b: no such &lt;thing&gt;</p>\n",
+		render(Problem{Status: 404, Title: "Not Found", Instance: "/a<b", Detail: "no such <thing>"}))
+	assert.Equal(t, "<p class=\"server-error\">503 Service Unavailable (abc123)</p>\n",
+		render(Problem{Status: 503, Title: "Service Unavailable", ErrorID: "abc123"}))
+
+	// other statuses use the built-in page
+	assert.Contains(t, render(Problem{Status: 409, Title: "Conflict"}), "<title>409 Conflict</title>")
+
+	// a template that fails falls back to the built-in page
+	renderer.Templates = template.Must(template.New("error.html").Parse(`{{.NoSuchField}}`))
+	assert.Contains(t, render(Problem{Status: 409, Title: "Conflict"}), "<title>409 Conflict</title>")
+}
+
+func TestMiddlewareRenderer(t *testing.T) {
+	var rendered Problem
+	h := Middleware{
+		Renderer: RendererFunc(func(w http.ResponseWriter, r *http.Request, p Problem) {
+			rendered = p
+			w.WriteHeader(p.Status)
+			fmt.Fprintln(w, "custom")
+		}),
+		Handler: HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+			return NotFound
+		}),
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "custom\n", string(w.Body.Bytes()))
+	assert.Equal(t, "Not Found", rendered.Title)
+}
@@ -1,8 +1,11 @@
 package httperr
 
 import (
+	"errors"
 	"io"
 	"net/http"
+
+	pkgerrors "github.com/pkg/errors"
 )
 
 // Response is an alias for http.Response that implements
@@ -27,6 +30,19 @@ func (re Response) Error() string {
 	return statusText
 }
 
+// ErrorCode returns the application error code from the X-Error-Code header
+// of the response. The numeric form of the code is not available.
+func (re Response) ErrorCode() (string, int) {
+	return re.Header.Get(ErrorCodeHeader), 0
+}
+
+// Is returns true if target is the Code named in the X-Error-Code header
+// of the response.
+func (re Response) Is(target error) bool {
+	code, _ := re.ErrorCode()
+	return isCode(code, target)
+}
+
 // WriteError copies the Response to the ResponseWriter.
 func (re Response) WriteError(w http.ResponseWriter, r *http.Request) {
 	for k, vv := range re.Header {
@@ -35,8 +51,39 @@ func (re Response) WriteError(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	w.WriteHeader(re.StatusCode)
-	io.Copy(w, re.Body)
+	if re.Body != nil {
+		io.Copy(w, re.Body)
+	}
+}
+
+// CloseBody closes the body of the response, which releases the connection
+// that it was read from. Callers that receive a Response error from a
+// Transport must close its body, unless the BufferErrorBodies ClientArg is
+// used. (The method cannot be called Close, since http.Response has a
+// field of that name.)
+func (re Response) CloseBody() error {
+	if re.Body == nil {
+		return nil
+	}
+	return re.Body.Close()
+}
+
+// CloseBody closes the body of the Response in the chain of err, if there
+// is one. It is a convenient way to release errors returned by a Client:
+//
+//	resp, err := client.Get(url)
+//	if err != nil {
+//	    defer httperr.CloseBody(err)
+//	    return err
+//	}
+func CloseBody(err error) error {
+	var re Response
+	if errors.As(err, &re) || errors.As(pkgerrors.Cause(err), &re) {
+		return re.CloseBody()
+	}
+	return nil
 }
 
 var _ error = Response{}
 var _ Writer = Response{}
+var _ errorCoder = Response{}
@@ -0,0 +1,138 @@
+package httperr
+
+import (
+	"errors"
+	"net/http"
+	"syscall"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+type retryabler interface {
+	Retryable() bool
+}
+
+type temporaryer interface {
+	Temporary() bool
+}
+
+// retryableStatus returns true if a request that failed with statusCode may
+// succeed if it is repeated. http.StatusInternalServerError is included,
+// although the request may have taken effect before the server failed.
+func retryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooEarly,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Retryable returns true if err describes a failure that may not recur if
+// the request is repeated, such as a timeout, a rate limit or an unavailable
+// server. It uses the first error in the chain of err that has a
+// Retryable() bool or Temporary() bool method, as implemented by Value,
+// Response, Problem and net.Error. Connections refused by the server, which
+// never received the request, and connections reset by the server are also
+// retryable. Other errors are not retryable, nor is a canceled context.
+//
+// Except for a Response, whose request is known, Retryable says nothing
+// about whether it is safe to repeat the request. A request that is not
+// idempotent may have taken effect even though it failed, in particular
+// with a http.StatusInternalServerError error.
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	for _, err := range []error{err, pkgerrors.Cause(err)} {
+		var r retryabler
+		if errors.As(err, &r) {
+			return r.Retryable()
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) {
+			return true
+		}
+		var t temporaryer
+		if errors.As(err, &t) {
+			return t.Temporary()
+		}
+	}
+	return false
+}
+
+// Retryable returns true if the status code of the error indicates that the
+// request may succeed if it is repeated, or if the error has a Retry-After
+// header.
+func (e Value) Retryable() bool {
+	statusCode, _ := e.StatusCodeAndText()
+	return retryableStatus(statusCode) || e.Header.Get("Retry-After") != ""
+}
+
+// Temporary is the same as Retryable. It allows the error to be classified
+// by code that checks for the Temporary method of net.Error.
+func (e Value) Temporary() bool {
+	return e.Retryable()
+}
+
+// Retryable returns true, since the error tells the client when to retry.
+func (e RetryError) Retryable() bool {
+	return true
+}
+
+// Temporary returns true, since the error tells the client when to retry.
+func (e RetryError) Temporary() bool {
+	return true
+}
+
+// Retryable returns true if the status code of the response indicates that
+// the request may succeed if it is repeated, or if the response has a
+// Retry-After header. A http.StatusInternalServerError response is not
+// retryable if its request is known and is not idempotent, since the
+// request may have taken effect.
+func (re Response) Retryable() bool {
+	if re.Header.Get("Retry-After") != "" {
+		return true
+	}
+	if re.StatusCode == http.StatusInternalServerError && re.Request != nil && !idempotent(re.Request) {
+		return false
+	}
+	return retryableStatus(re.StatusCode)
+}
+
+// idempotent returns true if repeating r has the same effect as sending it
+// once, because of its method or because it has an Idempotency-Key header.
+func idempotent(r *http.Request) bool {
+	switch r.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.Header.Get("Idempotency-Key") != ""
+}
+
+// Temporary is the same as Retryable.
+func (re Response) Temporary() bool {
+	return re.Retryable()
+}
+
+// Retryable returns true if the status of the problem indicates that the
+// request may succeed if it is repeated.
+func (p Problem) Retryable() bool {
+	statusCode, _ := p.StatusCodeAndText()
+	return retryableStatus(statusCode)
+}
+
+// Temporary is the same as Retryable.
+func (p Problem) Temporary() bool {
+	return p.Retryable()
+}
+
+var _ retryabler = Value{}
+var _ retryabler = RetryError{}
+var _ retryabler = Response{}
+var _ retryabler = Problem{}
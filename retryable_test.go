@@ -0,0 +1,62 @@
+package httperr
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		Err       error
+		Retryable bool
+	}{
+		{nil, false},
+		{fmt.Errorf("oops"), false},
+		{NotFound, false},
+		{BadRequest, false},
+		{InternalServerError, true},
+		{ServiceUnavailable, true},
+		{TooManyRequests, true},
+		{Value{StatusCode: http.StatusConflict, Header: http.Header{"Retry-After": {"10"}}}, true},
+		{NewTooManyRequests(nil, time.Second, nil), true},
+		{RetryError{Value: Value{StatusCode: http.StatusForbidden}, Delay: time.Minute}, true},
+		{Response{StatusCode: http.StatusBadGateway}, true},
+		{Response{StatusCode: http.StatusUnauthorized}, false},
+		{Response{StatusCode: http.StatusConflict, Header: http.Header{"Retry-After": {"10"}}}, true},
+		{Problem{Status: http.StatusGatewayTimeout}, true},
+		{Problem{Status: http.StatusUnprocessableEntity}, false},
+		{CircuitOpenError{Value: Value{StatusCode: http.StatusServiceUnavailable}}, true},
+		{pkgerrors.Wrap(ServiceUnavailable, "cannot frob the grob"), true},
+		{fmt.Errorf("cannot frob the grob: %w", NotFound), false},
+		{&url.Error{Op: "Get", URL: "/", Err: Response{StatusCode: http.StatusServiceUnavailable}}, true},
+		{&url.Error{Op: "Get", URL: "/", Err: context.Canceled}, false},
+		{&url.Error{Op: "Get", URL: "/", Err: context.DeadlineExceeded}, true},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}, false},
+		{Response{StatusCode: http.StatusInternalServerError, Request: &http.Request{Method: "GET"}}, true},
+		{Response{StatusCode: http.StatusInternalServerError, Request: &http.Request{Method: "POST"}}, false},
+		{Response{StatusCode: http.StatusInternalServerError, Request: &http.Request{Method: "POST", Header: http.Header{"Idempotency-Key": {"abc"}}}}, true},
+		{Response{StatusCode: http.StatusServiceUnavailable, Request: &http.Request{Method: "POST"}}, true},
+	} {
+		assert.Equal(t, tc.Retryable, Retryable(tc.Err), "%#v", tc.Err)
+	}
+}
+
+func TestRetryableClient(t *testing.T) {
+	client := Client(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable}, nil
+	})})
+	_, err := client.Get("http://example.com/")
+	assert.True(t, Retryable(err))
+}
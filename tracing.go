@@ -0,0 +1,47 @@
+package httperr
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// Tracer is notified when a request fails, so that it can annotate the
+// active span of a distributed trace. Middleware and Transport call
+// RecordError with the context of the request.
+//
+// The package github.com/crewjam/httperr/otelhttperr provides a Tracer for
+// OpenTelemetry.
+type Tracer interface {
+	RecordError(ctx context.Context, event TraceEvent)
+}
+
+// TraceEvent describes a failed request.
+type TraceEvent struct {
+	Err        error  // the error
+	StatusCode int    // the HTTP status code, or zero if no response was received
+	ErrorType  string // the application error code, or else the status code, or else the Go type of Err
+	Code       string // the application error code (optional)
+	Public     bool   // true if the error is revealed to the client
+	Client     bool   // true if the event was reported by Transport rather than Middleware
+}
+
+// newTraceEvent returns a TraceEvent describing err. statusCode is zero if
+// err is a transport error and no response was received.
+func newTraceEvent(err error, statusCode int, client bool) TraceEvent {
+	ev := TraceEvent{Err: err, StatusCode: statusCode, Client: client}
+	ev.Code, _ = ErrorCode(err)
+	ev.Public = isPublic(err)
+
+	switch {
+	case ev.Code != "":
+		ev.ErrorType = ev.Code
+	case ev.StatusCode != 0:
+		ev.ErrorType = strconv.Itoa(ev.StatusCode)
+	default:
+		ev.ErrorType = fmt.Sprintf("%T", pkgerrors.Cause(err))
+	}
+	return ev
+}
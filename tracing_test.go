@@ -0,0 +1,90 @@
+package httperr
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingTracer struct {
+	ctxs   []context.Context
+	events []TraceEvent
+}
+
+func (rt *recordingTracer) RecordError(ctx context.Context, event TraceEvent) {
+	rt.ctxs = append(rt.ctxs, ctx)
+	rt.events = append(rt.events, event)
+}
+
+type testContextKey struct{}
+
+func TestMiddlewareTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	mw := Middleware{
+		Tracer: tracer,
+		Handler: HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			if r.URL.Path == "/ok" {
+				return nil
+			}
+			return testUserNotFound.Public(errors.New("no such user"))
+		}),
+	}
+
+	for _, path := range []string{"/ok", "/users/alice"} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", path, nil)
+		r = r.WithContext(context.WithValue(r.Context(), testContextKey{}, path))
+		mw.ServeHTTP(w, r)
+	}
+
+	if !assert.Len(t, tracer.events, 1) {
+		return
+	}
+	assert.Equal(t, "/users/alice", tracer.ctxs[0].Value(testContextKey{}))
+	ev := tracer.events[0]
+	ev.Err = nil
+	assert.Equal(t, TraceEvent{
+		StatusCode: 404,
+		ErrorType:  "test_user_not_found",
+		Code:       "test_user_not_found",
+		Public:     true,
+	}, ev)
+}
+
+func TestTransportTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	transport := Transport{
+		Tracer: tracer,
+		Next: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			switch req.URL.Path {
+			case "/down":
+				return nil, &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+			case "/ok":
+				return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			}
+			return &http.Response{StatusCode: 503, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+		}),
+	}
+	client := http.Client{Transport: transport}
+
+	client.Get("/ok")
+	client.Get("/unavailable")
+	client.Get("/down")
+
+	if !assert.Len(t, tracer.events, 2) {
+		return
+	}
+	assert.Equal(t, 503, tracer.events[0].StatusCode)
+	assert.Equal(t, "503", tracer.events[0].ErrorType)
+	assert.True(t, tracer.events[0].Client)
+
+	assert.Equal(t, 0, tracer.events[1].StatusCode)
+	assert.Equal(t, "*net.OpError", tracer.events[1].ErrorType)
+}
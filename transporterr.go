@@ -0,0 +1,150 @@
+package httperr
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// TransportErrorKind classifies a TransportError.
+type TransportErrorKind string
+
+// Kinds of transport errors
+const (
+	TransportTimeout TransportErrorKind = "timeout" // the request timed out or its context deadline passed
+	TransportDNS     TransportErrorKind = "dns"     // the host name could not be resolved
+	TransportConnect TransportErrorKind = "connect" // a connection could not be established
+	TransportTLS     TransportErrorKind = "tls"     // the TLS handshake failed
+	TransportNetwork TransportErrorKind = "network" // the connection failed after it was established
+)
+
+// TransportError is a failure to get a response from a server, expressed as
+// an HTTP error so that it can be handled the same way as an error response.
+// Timeouts are http.StatusGatewayTimeout errors, and other failures are
+// http.StatusBadGateway errors. The underlying error is the original cause,
+// so errors.Is(err, context.DeadlineExceeded) and errors.As with
+// *net.DNSError work as before.
+//
+// Transport returns TransportErrors if the NormalizeTransportErrors ClientArg
+// is used.
+type TransportError struct {
+	Value
+	Kind TransportErrorKind
+}
+
+// NewTransportError returns err, a failure of http.RoundTripper, as a
+// TransportError. It returns nil if err is nil, and err unchanged if the
+// context of the request was canceled or err already has a status code,
+// like a TransportError or the CircuitOpenError of a CircuitBreaker.
+func NewTransportError(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return err
+	}
+	var scater statusCodeAndTexter
+	if errors.As(err, &scater) || errors.As(pkgerrors.Cause(err), &scater) {
+		return err
+	}
+
+	kind := classifyTransportError(err)
+	statusCode := http.StatusBadGateway
+	if kind == TransportTimeout {
+		statusCode = http.StatusGatewayTimeout
+	}
+	return TransportError{
+		Value: Value{StatusCode: statusCode, Err: err},
+		Kind:  kind,
+	}
+}
+
+func classifyTransportError(err error) TransportErrorKind {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return TransportTimeout
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return TransportDNS
+	}
+
+	var (
+		recordHeaderErr    tls.RecordHeaderError
+		unknownAuthority   x509.UnknownAuthorityError
+		hostnameErr        x509.HostnameError
+		certificateInvalid x509.CertificateInvalidError
+	)
+	if errors.As(err, &recordHeaderErr) || errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &certificateInvalid) {
+		return TransportTLS
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		switch opErr.Op {
+		case "dial":
+			return TransportConnect
+		case "remote error": // a TLS alert from the server
+			return TransportTLS
+		}
+	}
+	return TransportNetwork
+}
+
+// Timeout returns true if the request timed out. It allows the error to be
+// classified as a net.Error.
+func (e TransportError) Timeout() bool {
+	return e.Kind == TransportTimeout
+}
+
+// Retryable returns true if repeating the request might succeed. Timeouts,
+// connection failures and failures of established connections are
+// retryable. TLS failures are not, and DNS failures are only if they are
+// temporary.
+func (e TransportError) Retryable() bool {
+	switch e.Kind {
+	case TransportTLS:
+		return false
+	case TransportDNS:
+		var dnsErr *net.DNSError
+		return errors.As(e.Err, &dnsErr) && (dnsErr.IsTemporary || dnsErr.IsTimeout)
+	}
+	return true
+}
+
+// Temporary is the same as Retryable.
+func (e TransportError) Temporary() bool {
+	return e.Retryable()
+}
+
+// NormalizeTransportErrors returns a ClientArg that converts the errors
+// returned by the Next transport into TransportErrors, so that callers can
+// handle them with StatusCodeAndText and Retryable like error responses.
+func NormalizeTransportErrors() ClientArg {
+	return func(xport *Transport) {
+		xport.Next = normalizingTransport{next: xport.Next}
+	}
+}
+
+type normalizingTransport struct {
+	next http.RoundTripper
+}
+
+func (t normalizingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, NewTransportError(err)
+	}
+	return resp, nil
+}
+
+var _ net.Error = TransportError{}
+var _ retryabler = TransportError{}
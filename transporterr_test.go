@@ -0,0 +1,167 @@
+package httperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTransportErrors(t *testing.T) {
+	get := func(next http.RoundTripper, url string, timeout time.Duration) error {
+		ctx := context.Background()
+		if timeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+		client := Client(&http.Client{Transport: next}, NormalizeTransportErrors())
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	t.Run("connect", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		err := get(nil, server.URL, 0)
+		var transportErr TransportError
+		if assert.True(t, errors.As(err, &transportErr)) {
+			assert.Equal(t, TransportConnect, transportErr.Kind)
+		}
+		statusCode, _ := StatusCodeAndText(err)
+		assert.Equal(t, http.StatusBadGateway, statusCode)
+		assert.True(t, Retryable(err))
+		var opErr *net.OpError
+		assert.True(t, errors.As(err, &opErr))
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		server := httptest.NewUnstartedServer(http.NotFoundHandler())
+		server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+		server.StartTLS()
+		defer server.Close()
+
+		err := get(nil, server.URL, 0)
+		var transportErr TransportError
+		if assert.True(t, errors.As(err, &transportErr), "%v", err) {
+			assert.Equal(t, TransportTLS, transportErr.Kind)
+		}
+		assert.False(t, Retryable(err))
+	})
+
+	t.Run("dns", func(t *testing.T) {
+		next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "nowhere.invalid", IsNotFound: true}}
+		})
+		err := get(next, "http://nowhere.invalid/", 0)
+		var transportErr TransportError
+		if assert.True(t, errors.As(err, &transportErr)) {
+			assert.Equal(t, TransportDNS, transportErr.Kind)
+		}
+		assert.EqualError(t, err, `Get "http://nowhere.invalid/": 502 Bad Gateway: dial tcp: lookup nowhere.invalid: no such host`)
+		assert.False(t, Retryable(err))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		})
+		err := get(next, "http://example.com/", time.Millisecond)
+		statusCode, _ := StatusCodeAndText(err)
+		assert.Equal(t, http.StatusGatewayTimeout, statusCode)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		var netErr net.Error
+		if assert.True(t, errors.As(err, &netErr)) {
+			assert.True(t, netErr.Timeout())
+		}
+		assert.True(t, Retryable(err))
+	})
+
+	t.Run("network", func(t *testing.T) {
+		next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, io.ErrUnexpectedEOF
+		})
+		err := get(next, "http://example.com/", 0)
+		var transportErr TransportError
+		if assert.True(t, errors.As(err, &transportErr)) {
+			assert.Equal(t, TransportNetwork, transportErr.Kind)
+		}
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, req.Context().Err()
+		})
+		req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com/", nil)
+		_, err := Client(&http.Client{Transport: next}, NormalizeTransportErrors()).Do(req)
+		var transportErr TransportError
+		assert.False(t, errors.As(err, &transportErr))
+		assert.True(t, errors.Is(err, context.Canceled))
+	})
+}
+
+func TestNewTransportError(t *testing.T) {
+	assert.Nil(t, NewTransportError(nil))
+
+	err := NewTransportError(fmt.Errorf("oops"))
+	assert.Equal(t, err, NewTransportError(err))
+}
+
+func TestNewTransportErrorWithStatus(t *testing.T) {
+	for _, err := range []error{
+		ServiceUnavailable,
+		RetryError{Value: Value{StatusCode: http.StatusTooManyRequests}},
+		fmt.Errorf("wrapped: %w", NotFound),
+	} {
+		assert.Equal(t, err, NewTransportError(err))
+	}
+}
+
+func TestNormalizeTransportErrorsWithBreaker(t *testing.T) {
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection reset")
+	})
+
+	for _, breakerFirst := range []bool{true, false} {
+		t.Run(fmt.Sprintf("breakerFirst=%v", breakerFirst), func(t *testing.T) {
+			breaker := &CircuitBreaker{Threshold: 1, Cooldown: time.Minute}
+			args := []ClientArg{Breaker(breaker), NormalizeTransportErrors()}
+			if !breakerFirst {
+				args[0], args[1] = args[1], args[0]
+			}
+			client := Client(&http.Client{Transport: next}, args...)
+
+			_, err := client.Get("http://a.example.com/")
+			var transportErr TransportError
+			if assert.True(t, errors.As(err, &transportErr)) {
+				assert.Equal(t, TransportNetwork, transportErr.Kind)
+			}
+
+			// the circuit is open, and its error is not turned into a 502
+			_, err = client.Get("http://a.example.com/")
+			var openErr CircuitOpenError
+			assert.True(t, errors.As(err, &openErr))
+			assert.False(t, errors.As(err, &transportErr))
+			statusCode, _ := StatusCodeAndText(err)
+			assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+		})
+	}
+}
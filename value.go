@@ -17,8 +17,25 @@ type Value struct {
 	Status     string // the HTTP status text. If not supplied, http.StatusText(http.StatusCode) is used.
 	Public     bool
 	Header     http.Header // extra headers to add to the response (optional)
+
+	Code        string // a stable application error code, e.g. "user_not_found" (optional)
+	NumericCode int    // the numeric form of the application error code (optional)
+	Type        string // a URI that identifies the kind of error, e.g. a link to its documentation (optional)
+	Title       string // a short summary of the kind of error, used as the title of its Problem but not in its text (optional)
+
+	// MessageKey identifies a localized message that is shown to the client
+	// instead of the text of the underlying error. The message is chosen
+	// according to the Accept-Language header of the request from those
+	// registered with RegisterMessages, and formatted with MessageArgs.
+	MessageKey  string
+	MessageArgs []interface{}
+
+	stack *stack // where the error was created, if CaptureStackTraces is set
 }
 
+// ErrorCodeHeader is the response header that carries the application error code.
+const ErrorCodeHeader = "X-Error-Code"
+
 // StatusCodeAndText returns the status code and text of the error
 func (e Value) StatusCodeAndText() (int, string) {
 	if e.StatusCode == 0 {
@@ -38,7 +55,7 @@ func (e Value) StatusCodeAndText() (int, string) {
 
 func (e Value) Error() string {
 	statusCode, statusText := StatusCodeAndText(e)
-	if e.Public {
+	if e.Public || e.Err == nil {
 		return fmt.Sprintf("%d %s", statusCode, statusText)
 	}
 	return fmt.Sprintf("%d %s: %s", statusCode, statusText, e.Err.Error())
@@ -53,8 +70,62 @@ func (e Value) WriteError(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	code, message := e.StatusCodeAndText()
-	http.Error(w, message, code)
+	if e.Code != "" {
+		w.Header().Set(ErrorCodeHeader, e.Code)
+	}
+
+	p := e.Problem()
+	if language, localized, ok := localize(r, e.MessageKey, e.MessageArgs); ok {
+		w.Header().Set("Content-Language", language)
+		p.Detail = localized
+		if p.text != "" {
+			p.text = localized
+		}
+	}
+
+	p.ErrorID = ErrorID(r)
+
+	if debugEnabled(r) {
+		p.Debug = newDebugInfo(r, e)
+		writeDebug(w, r, p)
+		return
+	}
+
+	rendererFor(r).Render(w, r, p)
+}
+
+// Problem returns the problem details document that describes e. The
+// detail of the problem is the text of the underlying error only if e is Public.
+func (e Value) Problem() Problem {
+	statusCode, statusText := e.StatusCodeAndText()
+	p := Problem{
+		Type:        e.Type,
+		Title:       statusText,
+		Status:      statusCode,
+		Code:        e.Code,
+		NumericCode: e.NumericCode,
+	}
+	if e.Public && e.Err != nil {
+		if e.Status == "" {
+			p.Title = http.StatusText(statusCode)
+		}
+		p.Detail = e.Err.Error()
+	}
+	if e.Title != "" {
+		p.Title = e.Title
+		p.text = statusText
+	}
+	return p
+}
+
+// ErrorCode returns the application error code of the error
+func (e Value) ErrorCode() (string, int) {
+	return e.Code, e.NumericCode
+}
+
+// Is returns true if target is the Code of the error.
+func (e Value) Is(target error) bool {
+	return isCode(e.Code, target)
 }
 
 // Unwrap unwraps the Value error and returns the underlying error`
@@ -65,3 +136,4 @@ func (e Value) Unwrap() error {
 var _ error = Value{}
 var _ Writer = Value{}
 var _ statusCodeAndTexter = Value{}
+var _ errorCoder = Value{}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"testing"
 
+	pkgerrors "github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -88,3 +89,16 @@ func TestPublic(t *testing.T) {
 		})
 	}
 }
+
+func TestErrorStatus(t *testing.T) {
+	statusCode, text := ErrorStatus(pkgerrors.Wrap(Response{StatusCode: http.StatusBadGateway}, "fetch"))
+	assert.Equal(t, http.StatusBadGateway, statusCode)
+	assert.Equal(t, "Bad Gateway", text)
+
+	statusCode, text = ErrorStatus(fmt.Errorf("fetch: %w", NotFound))
+	assert.Equal(t, http.StatusNotFound, statusCode)
+	assert.Equal(t, "Not Found", text)
+
+	statusCode, _ = ErrorStatus(fmt.Errorf("cannot frob the grob"))
+	assert.Equal(t, http.StatusInternalServerError, statusCode)
+}
@@ -33,13 +33,15 @@ import (
 )
 
 // wrapWriter wraps an http.ResponseWriter, returning a proxy that
-// tracks the response.
-func wrapWriter(w http.ResponseWriter) (*basicWriter, http.ResponseWriter) {
+// tracks the response. If observe is nil, error responses are captured
+// rather than written. Otherwise they are passed straight through, and
+// observe is called with the status code before the header is written.
+func wrapWriter(w http.ResponseWriter, observe func(statusCode int)) (*basicWriter, http.ResponseWriter) {
 	_, isCloseNotifier := w.(http.CloseNotifier)
 	_, isFlusher := w.(http.Flusher)
 	_, isHijacker := w.(http.Hijacker)
 
-	bw := basicWriter{ResponseWriter: w}
+	bw := basicWriter{ResponseWriter: w, observe: observe}
 	if isCloseNotifier && isFlusher && isHijacker {
 		rv := fancyWriter{bw}
 		return &rv.basicWriter, &rv
@@ -57,11 +59,15 @@ type basicWriter struct {
 	statusCode int
 	copy       *http.Response
 	body       *bytes.Buffer
+	observe    func(statusCode int)
 }
 
 func (b *basicWriter) WriteHeader(code int) {
 	b.statusCode = code
-	if code < 400 {
+	if code >= 400 && b.observe != nil {
+		b.observe(code)
+	}
+	if code < 400 || b.observe != nil {
 		b.ResponseWriter.WriteHeader(code)
 		return
 	}